@@ -6,15 +6,41 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+
+	"wb-l0/internal/commands"
 	"wb-l0/internal/server"
 )
 
 func main() {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve()
+	case "migrate":
+		run(commands.Migrate, args)
+	case "reencrypt":
+		run(commands.Reencrypt, args)
+	case "export":
+		run(commands.Export, args)
+	case "import":
+		run(commands.Import, args)
+	default:
+		log.Printf("unknown command \"%s\"\n", command)
+		os.Exit(2)
+	}
+}
+
+func serve() {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	srv, err := server.Boot(ctx)
 	if err != nil {
 		log.Println(err)
+		cancel()
 		return
 	}
 
@@ -29,3 +55,16 @@ func main() {
 
 	log.Println("shutdown complete")
 }
+
+// run выполняет одноразовую команду, прерывая её по сигналу.
+func run(command func(ctx context.Context, args []string) error, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	err := command(ctx, args)
+	if err != nil {
+		log.Println(err)
+		cancel()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"wb-l0/internal/config/env"
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/export"
+	"wb-l0/pkg/envelope"
+)
+
+// Export - команда выгрузки заказов из Postgres: export [-format ndjson|csv] [-gzip] [-o FILE] [-customer-id ID]
+// [-delivery-service NAME] [-state STATE] [-created-from DATE] [-created-to DATE].
+//
+// Заказы выгружаются из хранилища, заданного STORAGE_DRIVER, при шардировании - из всех шардов. Фильтры совпадают с фильтрами списка заказов в HTTP API. Без -o выгрузка пишется в стандартный вывод.
+func Export(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := flags.String("format", string(export.FormatNDJSON), "output format: ndjson or csv")
+	compress := flags.Bool("gzip", false, "compress output with gzip")
+	output := flags.String("o", "", "output file, standard output by default")
+	customerID := flags.String("customer-id", "", "export only orders of this customer")
+	deliveryService := flags.String("delivery-service", "", "export only orders of this delivery service")
+	state := flags.String("state", "", "export only orders in this state")
+	createdFrom := flags.String("created-from", "", "export only orders created at or after this time")
+	createdTo := flags.String("created-to", "", "export only orders created before this time")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	filter := order.Filter{
+		CustomerID:      *customerID,
+		DeliveryService: *deliveryService,
+		State:           order.State(*state),
+	}
+
+	if filter.State != "" && !filter.State.Valid() {
+		return &order.UnknownStateError{State: filter.State}
+	}
+
+	filter.CreatedFrom, err = parseFilterTime(*createdFrom)
+	if err != nil {
+		return fmt.Errorf("invalid created-from: %s", err)
+	}
+
+	filter.CreatedTo, err = parseFilterTime(*createdTo)
+	if err != nil {
+		return fmt.Errorf("invalid created-to: %s", err)
+	}
+
+	sealer, err := envelope.NewSealerFromFile(env.ReadEncryptionConfig().KeyringPath)
+	if err != nil {
+		return err
+	}
+
+	database, err := newPostgresStorageFromConfig(ctx, env.ReadStorageConfig(), sealer)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if *output != "" {
+		file, err = os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %s", err)
+		}
+
+		out = file
+	}
+
+	exported := 0
+	writer := export.NewWriter(out, format, *compress)
+	err = database.ExportOrders(ctx, filter, func(o *order.Order) error {
+		exported++
+		return writer.Write(o)
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+
+	// Ошибка закрытия файла может означать, что выгрузка не записана на диск целиком.
+	if file != nil {
+		closeErr := file.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("error closing output file: %s", closeErr)
+		}
+	}
+
+	if err != nil {
+		if *output != "" {
+			os.Remove(*output)
+		}
+
+		return fmt.Errorf("error exporting orders: %s", err)
+	}
+
+	log.Printf("exported %d orders\n", exported)
+	return nil
+}
+
+// parseFilterTime разбирает границу интервала дат в формате RFC 3339 или YYYY-MM-DD. Пустая строка означает,
+// что граница не задана.
+func parseFilterTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return parsed, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
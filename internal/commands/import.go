@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"wb-l0/internal/config/env"
+	"wb-l0/internal/order"
+	"wb-l0/pkg/envelope"
+)
+
+// maxImportLineSize - максимальный размер строки импортируемого файла.
+const maxImportLineSize = 16 << 20
+
+// importSubject - subject, под которым сохраняются исходные сообщения загруженных заказов: строки файла.
+const importSubject = "import"
+
+// Import - команда загрузки заказов в основное хранилище из файлов NDJSON: import [-batch N] [-rejects FILE] FILE...
+//
+// Каждая строка файла - заказ в том же формате, что и сообщения NATS; файлы, сжатые gzip, распаковываются.
+// Заказы проверяются так же, как при получении из NATS, уже сохранённые заказы пропускаются. Строки, которые
+// не удалось загрузить, записываются в файл отказов вместе с причиной отказа.
+func Import(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	batchSize := flags.Int("batch", 500, "number of orders saved in one transaction")
+	rejectsPath := flags.String("rejects", "import-rejects.ndjson", "file for lines that could not be imported")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("usage: import [-batch N] [-rejects FILE] FILE...")
+	}
+
+	sealer, err := envelope.NewSealerFromFile(env.ReadEncryptionConfig().KeyringPath)
+	if err != nil {
+		return err
+	}
+
+	database, err := newPostgresStorageFromConfig(ctx, env.ReadStorageConfig(), sealer)
+	if err != nil {
+		return err
+	}
+
+	importer := &importer{database: database, batchSize: max(*batchSize, 1), rejectsPath: *rejectsPath}
+	defer importer.closeRejects()
+
+	for _, path := range flags.Args() {
+		err = importer.importFile(ctx, path)
+		if err != nil {
+			break
+		}
+	}
+
+	log.Printf(
+		"inserted %d, skipped %d, failed %d lines\n",
+		importer.inserted, importer.skipped, importer.failed,
+	)
+	if importer.failed > 0 {
+		log.Printf("rejected lines are written to %s\n", *rejectsPath)
+	}
+
+	return err
+}
+
+type importer struct {
+	database    order.BatchCreator
+	batchSize   int
+	rejectsPath string
+	rejects     *os.File
+
+	batch []importLine
+
+	inserted int
+	skipped  int
+	failed   int
+}
+
+// importLine - заказ, прочитанный из строки line файла file.
+type importLine struct {
+	file  string
+	line  int
+	data  []byte
+	order *order.Order
+}
+
+// importReject - запись файла отказов. Для заказов, не прошедших проверку, в Violations перечисляются все
+// нарушения.
+type importReject struct {
+	File       string                 `json:"file"`
+	Line       int                    `json:"line"`
+	Error      string                 `json:"error"`
+	Violations []order.FieldViolation `json:"violations,omitempty"`
+	Data       string                 `json:"data"`
+}
+
+func (i *importer) importFile(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %s", path, err)
+	}
+	defer file.Close()
+
+	reader, err := decompress(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %s", path, err)
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64<<10), maxImportLineSize)
+	for number := 1; scanner.Scan(); number++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		line := importLine{file: path, line: number, data: bytes.Clone(data)}
+		err = i.add(ctx, line)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return fmt.Errorf("error reading %s: %s", path, err)
+	}
+
+	return i.flush(ctx)
+}
+
+// decompress распаковывает поток, если он сжат gzip. Закрытие результата не закрывает r.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(buffered)
+	}
+
+	return io.NopCloser(buffered), nil
+}
+
+// add проверяет заказ так же, как consumer.Consumer, и добавляет его в пакет, сохраняя пакет по заполнении.
+func (i *importer) add(ctx context.Context, line importLine) error {
+	var o order.Order
+	err := json.Unmarshal(line.data, &o)
+	if err != nil {
+		return i.reject(line, fmt.Errorf("error unmarshalling order: %s", err))
+	}
+
+	err = o.Validate()
+	if err != nil {
+		return i.reject(line, err)
+	}
+
+	line.order = &o
+	i.batch = append(i.batch, line)
+	if len(i.batch) >= i.batchSize {
+		return i.flush(ctx)
+	}
+
+	return nil
+}
+
+func (i *importer) flush(ctx context.Context) error {
+	if len(i.batch) == 0 {
+		return nil
+	}
+
+	orders := make([]*order.Order, len(i.batch))
+	messages := make([]*order.RawMessage, len(i.batch))
+	receivedAt := time.Now()
+	for j, line := range i.batch {
+		orders[j] = line.order
+		messages[j] = &order.RawMessage{Subject: importSubject, Body: line.data, ReceivedAt: receivedAt}
+	}
+
+	errs, err := i.database.CreateOrders(ctx, orders, messages)
+	if err != nil {
+		return fmt.Errorf("error saving orders from %s, lines %d-%d: %s",
+			i.batch[0].file, i.batch[0].line, i.batch[len(i.batch)-1].line, err)
+	}
+
+	for j, line := range i.batch {
+		switch {
+		case errs[j] == nil:
+			i.inserted++
+		case errors.Is(errs[j], order.ErrAlreadyExists):
+			i.skipped++
+		default:
+			err = i.reject(line, errs[j])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	i.batch = i.batch[:0]
+	return nil
+}
+
+// reject записывает строку в файл отказов. Файл создаётся при первом отказе.
+func (i *importer) reject(line importLine, reason error) error {
+	i.failed++
+	if i.rejects == nil {
+		file, err := os.Create(i.rejectsPath)
+		if err != nil {
+			return fmt.Errorf("error creating rejects file: %s", err)
+		}
+
+		i.rejects = file
+	}
+
+	record := importReject{
+		File:  line.file,
+		Line:  line.line,
+		Error: reason.Error(),
+		Data:  string(line.data),
+	}
+
+	var validationErr *order.ValidationError
+	if errors.As(reason, &validationErr) {
+		record.Violations = validationErr.Violations
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = i.rejects.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("error writing rejects file: %s", err)
+	}
+
+	return nil
+}
+
+func (i *importer) closeRejects() {
+	if i.rejects != nil {
+		i.rejects.Close()
+	}
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"wb-l0/internal/config/env"
+	"wb-l0/internal/migrations"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Migrate - команда управления схемой базы данных: migrate up, migrate down [-steps N], migrate status.
+func Migrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	cfg := env.ReadPostgresConfig()
+	conn, err := pgx.Connect(ctx, cfg.URL)
+	if err != nil {
+		return fmt.Errorf("error connecting to postgres: %s", err)
+	}
+	defer conn.Close(context.Background())
+
+	migrator, err := migrations.NewMigrator(conn)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("applied %d migrations\n", applied)
+		return nil
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "number of migrations to revert")
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		reverted, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("reverted %d migrations\n", reverted)
+		return nil
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		return printMigrationStatus(statuses)
+	default:
+		return fmt.Errorf("unknown migrate subcommand \"%s\"", args[0])
+	}
+}
+
+func printMigrationStatus(statuses []migrations.Status) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+		}
+
+		fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+
+	return writer.Flush()
+}
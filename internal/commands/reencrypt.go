@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"wb-l0/internal/config/env"
+	"wb-l0/pkg/envelope"
+)
+
+// Reencrypt - команда перешифровки персональных данных активным ключом: reencrypt [-batch N].
+//
+// Используется после добавления в связку ключей нового активного ключа, а также для шифрования данных,
+// сохранённых до включения шифрования. При шардировании перешифровываются все шарды. Старый ключ можно удалять из связки после завершения команды
+// и истечения времени жизни заказов в Redis.
+func Reencrypt(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reencrypt", flag.ContinueOnError)
+	batchSize := flags.Int("batch", 500, "number of rows updated in one transaction")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	sealer, err := envelope.NewSealerFromFile(env.ReadEncryptionConfig().KeyringPath)
+	if err != nil {
+		return err
+	}
+
+	database, err := newPostgresStorageFromConfig(ctx, env.ReadStorageConfig(), sealer)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := database.ReencryptDeliveries(ctx, *batchSize)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("re-encrypted %d deliveries\n", deliveries)
+
+	messages, err := database.ReencryptRawMessages(ctx, *batchSize)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("re-encrypted %d raw messages\n", messages)
+
+	archived, err := database.ReencryptArchive(ctx, *batchSize)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("re-encrypted %d archived orders\n", archived)
+	return nil
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/repository"
+	"wb-l0/pkg/envelope"
+)
+
+// postgresStorage - основное хранилище в Postgres, одиночном или шардированном, с операциями, которые нужны
+// командам обслуживания.
+type postgresStorage interface {
+	order.BatchCreator
+	order.Exporter
+	ReencryptDeliveries(ctx context.Context, batchSize int) (int, error)
+	ReencryptRawMessages(ctx context.Context, batchSize int) (int, error)
+	ReencryptArchive(ctx context.Context, batchSize int) (int, error)
+}
+
+// newPostgresStorageFromConfig подключается к основному хранилищу, заданному STORAGE_DRIVER. Команды работают
+// только с Postgres, в том числе шардированным, чтобы затрагивать те же шарды, что и сервис.
+func newPostgresStorageFromConfig(
+	ctx context.Context, cfg *config.Config, sealer *envelope.Sealer,
+) (postgresStorage, error) {
+	switch cfg.Storage.Driver {
+	case "postgres":
+		return repository.NewPostgresRepositoryFromConfig(ctx, cfg.Postgres, sealer)
+	case "sharded":
+		return repository.NewShardedRepositoryFromConfig(ctx, cfg.Sharding, cfg.Postgres, sealer)
+	default:
+		return nil, fmt.Errorf("command is not supported for storage driver \"%s\"", cfg.Storage.Driver)
+	}
+}
@@ -1,21 +1,162 @@
 package env
 
-import "wb-l0/internal/config"
+import (
+	"time"
+
+	"wb-l0/internal/config"
+)
 
 func ReadConfig() *config.Config {
+	storage := ReadStorageConfig()
+
 	return &config.Config{
-		Postgres: config.PostgresConnection{
-			URL: requireEnv("POSTGRES_URL"),
-		},
+		Storage:  storage.Storage,
+		Postgres: storage.Postgres,
+		Sharding: storage.Sharding,
+		SQLite:   storage.SQLite,
 		Redis: config.RedisConnection{
-			Address: envOrDefault("REDIS_ADDRESS", "127.0.0.1:6379"),
+			Enabled:  boolOrDefault("REDIS_ENABLED", false),
+			Address:  envOrDefault("REDIS_ADDRESS", "127.0.0.1:6379"),
+			Password: envOrDefault("REDIS_PASSWORD", ""),
+			DB:       intOrDefault("REDIS_DB", 0),
+			TLS:      boolOrDefault("REDIS_TLS", false),
+
+			KeyPrefix: envOrDefault("REDIS_KEY_PREFIX", "wb-l0:order:"),
+			TTL:       durationOrDefault("REDIS_TTL", time.Hour),
+
+			DialTimeout:     durationOrDefault("REDIS_DIAL_TIMEOUT", time.Second),
+			ReadTimeout:     durationOrDefault("REDIS_READ_TIMEOUT", 200*time.Millisecond),
+			WriteTimeout:    durationOrDefault("REDIS_WRITE_TIMEOUT", 200*time.Millisecond),
+			FailureCooldown: durationOrDefault("REDIS_FAILURE_COOLDOWN", 5*time.Second),
 		},
 		Server: config.Server{
 			BindAddress: envOrDefault("BIND_ADDRESS", ":8080"),
+			AdminToken:  envOrDefault("ADMIN_TOKEN", ""),
 		},
 		Nats: config.NatsConnection{
 			URL:     requireEnv("NATS_URL"),
 			Subject: requireEnv("NATS_SUBJECT"),
 		},
+		Cache: config.Cache{
+			NegativeTTL:  durationOrDefault("CACHE_NEGATIVE_TTL", 10*time.Second),
+			NegativeSize: intOrDefault("CACHE_NEGATIVE_SIZE", 10000),
+
+			ListenChanges: boolOrDefault("CACHE_LISTEN_CHANGES", true),
+			SnapshotPath:  envOrDefault("CACHE_SNAPSHOT_PATH", ""),
+		},
+		Encryption:  ReadEncryptionConfig(),
+		Retention:   readRetentionConfig(),
+		Consistency: readConsistencyConfig(),
+		Analytics: config.Analytics{
+			CacheTTL: durationOrDefault("ANALYTICS_CACHE_TTL", time.Minute),
+		},
+		Tracing: config.Tracing{
+			Exporter:     envOrDefault("TRACING_EXPORTER", ""),
+			OTLPEndpoint: envOrDefault("TRACING_OTLP_ENDPOINT", "localhost:4318"),
+			OTLPInsecure: boolOrDefault("TRACING_OTLP_INSECURE", false),
+			FilePath:     envOrDefault("TRACING_FILE", ""),
+			ServiceName:  envOrDefault("TRACING_SERVICE_NAME", "wb-l0"),
+			SampleRatio:  floatOrDefault("TRACING_SAMPLE_RATIO", 1),
+		},
+	}
+}
+
+// readRetentionConfig читает параметры очистки старых заказов. Параметры проверяются, только если очистка
+// включена: иначе они не используются.
+func readRetentionConfig() config.Retention {
+	durationOf, intOf := durationOrDefault, intOrDefault
+	enabled := boolOrDefault("RETENTION_ENABLED", false)
+	if enabled {
+		durationOf, intOf = positiveDurationOrDefault, positiveIntOrDefault
+	}
+
+	return config.Retention{
+		Enabled:   enabled,
+		MaxAge:    durationOf("RETENTION_MAX_AGE", 365*24*time.Hour),
+		Archive:   boolOrDefault("RETENTION_ARCHIVE", false),
+		BatchSize: intOf("RETENTION_BATCH_SIZE", 500),
+		Interval:  durationOf("RETENTION_INTERVAL", time.Hour),
+	}
+}
+
+// readConsistencyConfig читает параметры сверки кэшей. Период проверяется, только если периодическая сверка
+// включена. Размер выборки проверяется всегда: он также используется по умолчанию при сверке, запрошенной вручную.
+func readConsistencyConfig() config.Consistency {
+	durationOf := durationOrDefault
+	enabled := boolOrDefault("CONSISTENCY_CHECK_ENABLED", false)
+	if enabled {
+		durationOf = positiveDurationOrDefault
+	}
+
+	return config.Consistency{
+		Enabled:  enabled,
+		Interval: durationOf("CONSISTENCY_CHECK_INTERVAL", time.Hour),
+		Sample:   nonNegativeIntOrDefault("CONSISTENCY_CHECK_SAMPLE", 1000),
+		Repair:   boolOrDefault("CONSISTENCY_CHECK_REPAIR", false),
+	}
+}
+
+// ReadStorageConfig читает только параметры основного хранилища (Storage, Postgres, Sharding и SQLite).
+// Используется командами, которым не нужна остальная конфигурация сервиса.
+func ReadStorageConfig() *config.Config {
+	storage := config.Storage{
+		Driver: envOrDefault("STORAGE_DRIVER", "postgres"),
+	}
+
+	// Параметры Postgres обязательны, только если он используется в качестве основного хранилища.
+	// При шардировании адреса баз данных задаются для каждого шарда отдельно.
+	var postgres config.PostgresConnection
+	var sharding config.Sharding
+	switch storage.Driver {
+	case "postgres":
+		postgres = ReadPostgresConfig()
+	case "sharded":
+		postgres = readPostgresOptions()
+		sharding = config.Sharding{
+			Shards:         requireMap("SHARDING_SHARDS"),
+			Keys:           mapOrDefault("SHARDING_KEYS", nil),
+			DefaultShard:   envOrDefault("SHARDING_DEFAULT_SHARD", ""),
+			Locator:        envOrDefault("SHARDING_LOCATOR", "directory"),
+			DirectoryShard: envOrDefault("SHARDING_DIRECTORY_SHARD", ""),
+			UIDPattern:     envOrDefault("SHARDING_UID_PATTERN", ""),
+		}
+	}
+
+	return &config.Config{
+		Storage:  storage,
+		Postgres: postgres,
+		Sharding: sharding,
+		SQLite: config.SQLite{
+			Path: envOrDefault("SQLITE_PATH", "wb-l0.db"),
+		},
+	}
+}
+
+// ReadPostgresConfig читает только параметры подключения к Postgres. Используется командами,
+// которым не нужна остальная конфигурация сервиса.
+func ReadPostgresConfig() config.PostgresConnection {
+	cfg := readPostgresOptions()
+	cfg.URL = requireEnv("POSTGRES_URL")
+	return cfg
+}
+
+// readPostgresOptions читает параметры подключения к Postgres, кроме адреса.
+func readPostgresOptions() config.PostgresConnection {
+	return config.PostgresConnection{
+		MaxConns:    intOrDefault("POSTGRES_MAX_CONNS", 0),
+		ReadMode:    envOrDefault("POSTGRES_READ_MODE", "aggregated"),
+		AutoMigrate: boolOrDefault("POSTGRES_AUTO_MIGRATE", false),
+
+		ReplicaURLs:          listOrDefault("POSTGRES_REPLICA_URLS", nil),
+		ReplicaCheckInterval: positiveDurationOrDefault("POSTGRES_REPLICA_CHECK_INTERVAL", 5*time.Second),
+		ReplicaMaxLag:        durationOrDefault("POSTGRES_REPLICA_MAX_LAG", 0),
+		ReadYourWritesWindow: durationOrDefault("POSTGRES_READ_YOUR_WRITES_WINDOW", 10*time.Second),
+	}
+}
+
+// ReadEncryptionConfig читает только параметры шифрования персональных данных.
+func ReadEncryptionConfig() config.Encryption {
+	return config.Encryption{
+		KeyringPath: envOrDefault("ENCRYPTION_KEYRING_PATH", ""),
 	}
 }
@@ -0,0 +1,33 @@
+package env
+
+import "testing"
+
+func TestReadRetentionConfig(t *testing.T) {
+	t.Setenv("RETENTION_INTERVAL", "0")
+	t.Setenv("RETENTION_BATCH_SIZE", "0")
+
+	t.Setenv("RETENTION_ENABLED", "false")
+	if cfg := readRetentionConfig(); cfg.Enabled || cfg.Interval != 0 || cfg.BatchSize != 0 {
+		t.Errorf("got %+v for disabled retention", cfg)
+	}
+
+	t.Setenv("RETENTION_ENABLED", "true")
+	assertPanics(t, "enabled retention", func() { readRetentionConfig() })
+}
+
+func TestReadConsistencyConfig(t *testing.T) {
+	t.Setenv("CONSISTENCY_CHECK_INTERVAL", "0")
+
+	t.Setenv("CONSISTENCY_CHECK_ENABLED", "false")
+	if cfg := readConsistencyConfig(); cfg.Enabled || cfg.Interval != 0 || cfg.Sample != 1000 {
+		t.Errorf("got %+v for disabled consistency check", cfg)
+	}
+
+	t.Setenv("CONSISTENCY_CHECK_ENABLED", "true")
+	assertPanics(t, "enabled consistency check", func() { readConsistencyConfig() })
+
+	t.Setenv("CONSISTENCY_CHECK_ENABLED", "false")
+	t.Setenv("CONSISTENCY_CHECK_INTERVAL", "1h")
+	t.Setenv("CONSISTENCY_CHECK_SAMPLE", "-1")
+	assertPanics(t, "negative sample", func() { readConsistencyConfig() })
+}
@@ -3,6 +3,9 @@ package env
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func requireEnv(key string) string {
@@ -20,3 +23,121 @@ func envOrDefault(key string, def string) string {
 	}
 	return def
 }
+
+func intOrDefault(key string, def int) int {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+
+	value, err := strconv.Atoi(env)
+	if err != nil {
+		panic(fmt.Sprintf("environment variable %s must be an integer: %s", key, err))
+	}
+	return value
+}
+
+// positiveIntOrDefault - то же, что intOrDefault, но значение должно быть больше нуля.
+func positiveIntOrDefault(key string, def int) int {
+	value := intOrDefault(key, def)
+	if value <= 0 {
+		panic(fmt.Sprintf("environment variable %s must be positive, got %d", key, value))
+	}
+	return value
+}
+
+// nonNegativeIntOrDefault - то же, что intOrDefault, но значение не должно быть отрицательным.
+func nonNegativeIntOrDefault(key string, def int) int {
+	value := intOrDefault(key, def)
+	if value < 0 {
+		panic(fmt.Sprintf("environment variable %s must not be negative, got %d", key, value))
+	}
+	return value
+}
+
+func boolOrDefault(key string, def bool) bool {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+
+	value, err := strconv.ParseBool(env)
+	if err != nil {
+		panic(fmt.Sprintf("environment variable %s must be a boolean: %s", key, err))
+	}
+	return value
+}
+
+func durationOrDefault(key string, def time.Duration) time.Duration {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+
+	value, err := time.ParseDuration(env)
+	if err != nil {
+		panic(fmt.Sprintf("environment variable %s must be a duration: %s", key, err))
+	}
+	return value
+}
+
+// positiveDurationOrDefault - то же, что durationOrDefault, но значение должно быть больше нуля.
+func positiveDurationOrDefault(key string, def time.Duration) time.Duration {
+	value := durationOrDefault(key, def)
+	if value <= 0 {
+		panic(fmt.Sprintf("environment variable %s must be positive, got %s", key, value))
+	}
+	return value
+}
+
+func floatOrDefault(key string, def float64) float64 {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+
+	value, err := strconv.ParseFloat(env, 64)
+	if err != nil {
+		panic(fmt.Sprintf("environment variable %s must be a number: %s", key, err))
+	}
+	return value
+}
+
+// listOrDefault читает список значений, разделённых запятыми. Пустые значения пропускаются.
+func listOrDefault(key string, def []string) []string {
+	env, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+
+	var values []string
+	for _, value := range strings.Split(env, ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// mapOrDefault читает пары ключ=значение, разделённые запятыми.
+func mapOrDefault(key string, def map[string]string) map[string]string {
+	if _, ok := os.LookupEnv(key); !ok {
+		return def
+	}
+
+	values := make(map[string]string)
+	for _, pair := range listOrDefault(key, nil) {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			panic(fmt.Sprintf("environment variable %s must be a list of key=value pairs", key))
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+func requireMap(key string) map[string]string {
+	requireEnv(key)
+	return mapOrDefault(key, nil)
+}
@@ -0,0 +1,63 @@
+package env
+
+import (
+	"testing"
+	"time"
+)
+
+// assertPanics проверяет, что fn вызывает panic.
+func assertPanics(t *testing.T, name string, fn func()) {
+	t.Helper()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: expected panic", name)
+		}
+	}()
+
+	fn()
+}
+
+func TestPositiveIntOrDefault(t *testing.T) {
+	if got := positiveIntOrDefault("WBL0_TEST_POSITIVE_INT", 5); got != 5 {
+		t.Errorf("got %d for unset variable, want default 5", got)
+	}
+
+	t.Setenv("WBL0_TEST_POSITIVE_INT", "10")
+	if got := positiveIntOrDefault("WBL0_TEST_POSITIVE_INT", 5); got != 10 {
+		t.Errorf("got %d, want 10", got)
+	}
+
+	for _, value := range []string{"0", "-1", "abc"} {
+		t.Setenv("WBL0_TEST_POSITIVE_INT", value)
+		assertPanics(t, value, func() { positiveIntOrDefault("WBL0_TEST_POSITIVE_INT", 5) })
+	}
+}
+
+func TestNonNegativeIntOrDefault(t *testing.T) {
+	for value, want := range map[string]int{"0": 0, "10": 10} {
+		t.Setenv("WBL0_TEST_NON_NEGATIVE_INT", value)
+		if got := nonNegativeIntOrDefault("WBL0_TEST_NON_NEGATIVE_INT", 5); got != want {
+			t.Errorf("got %d for %q, want %d", got, value, want)
+		}
+	}
+
+	t.Setenv("WBL0_TEST_NON_NEGATIVE_INT", "-1")
+	assertPanics(t, "-1", func() { nonNegativeIntOrDefault("WBL0_TEST_NON_NEGATIVE_INT", 5) })
+}
+
+func TestPositiveDurationOrDefault(t *testing.T) {
+	if got := positiveDurationOrDefault("WBL0_TEST_POSITIVE_DURATION", time.Hour); got != time.Hour {
+		t.Errorf("got %s for unset variable, want default %s", got, time.Hour)
+	}
+
+	t.Setenv("WBL0_TEST_POSITIVE_DURATION", "5s")
+	if got := positiveDurationOrDefault("WBL0_TEST_POSITIVE_DURATION", time.Hour); got != 5*time.Second {
+		t.Errorf("got %s, want 5s", got)
+	}
+
+	for _, value := range []string{"0", "0s", "-1m", "abc"} {
+		t.Setenv("WBL0_TEST_POSITIVE_DURATION", value)
+		assertPanics(t, value, func() { positiveDurationOrDefault("WBL0_TEST_POSITIVE_DURATION", time.Hour) })
+	}
+}
@@ -1,25 +1,168 @@
 package config
 
+import "time"
+
 type Config struct {
-	Postgres PostgresConnection
-	Redis    RedisConnection
-	Nats     NatsConnection
-	Server   Server
+	Storage     Storage
+	Postgres    PostgresConnection
+	SQLite      SQLite
+	Sharding    Sharding
+	Redis       RedisConnection
+	Nats        NatsConnection
+	Server      Server
+	Cache       Cache
+	Retention   Retention
+	Consistency Consistency
+	Encryption  Encryption
+	Tracing     Tracing
+	Analytics   Analytics
+}
+
+// Storage описывает основное хранилище заказов.
+type Storage struct {
+	// Driver - "postgres", "sharded" (несколько баз данных Postgres, см. Sharding) или "sqlite".
+	Driver string
+}
+
+// Sharding описывает распределение заказов между несколькими базами данных Postgres. Параметры подключения,
+// кроме адреса, берутся из PostgresConnection.
+type Sharding struct {
+	// Shards - адреса баз данных по именам шардов.
+	Shards map[string]string
+	// Keys - имя шарда для каждого значения Order.ShardKey.
+	Keys map[string]string
+	// DefaultShard - шард для заказов, ShardKey которых нет в Keys. Пустое значение означает, что такие заказы
+	// не сохраняются.
+	DefaultShard string
+	// Locator - способ определить шард по UID заказа: "directory" (по таблице order_shards в шарде
+	// DirectoryShard) или "uid" (по ShardKey, извлекаемому из UID выражением UIDPattern).
+	Locator        string
+	DirectoryShard string
+	// UIDPattern - регулярное выражение, первая группа которого выделяет ShardKey из UID заказа.
+	UIDPattern string
+}
+
+type SQLite struct {
+	// Path - путь к файлу базы данных SQLite.
+	Path string
 }
 
 type PostgresConnection struct {
 	URL string
+	// MaxConns - максимальный размер пула соединений. Нулевое значение оставляет размер по умолчанию.
+	MaxConns int
+	// ReadMode - способ получения заказа: "aggregated" (одним запросом) или "two-queries".
+	ReadMode string
+	// AutoMigrate включает применение миграций схемы базы данных при запуске сервиса.
+	AutoMigrate bool
+
+	// ReplicaURLs - адреса реплик, с которых читаются заказы. Пустой список означает чтение с ведущего сервера.
+	ReplicaURLs []string
+	// ReplicaCheckInterval - период проверки доступности реплик.
+	ReplicaCheckInterval time.Duration
+	// ReplicaMaxLag - допустимое отставание реплики, при превышении которого она не используется.
+	// Нулевое значение отключает проверку отставания.
+	ReplicaMaxLag time.Duration
+	// ReadYourWritesWindow - время после сохранения заказа, в течение которого он читается с ведущего сервера.
+	ReadYourWritesWindow time.Duration
 }
 
 type RedisConnection struct {
-	Address string
+	// Enabled включает Redis в качестве общего для всех экземпляров кэша второго уровня.
+	Enabled  bool
+	Address  string
+	Password string
+	DB       int
+	TLS      bool
+
+	// KeyPrefix добавляется к UID заказа при формировании ключа.
+	KeyPrefix string
+	// TTL - время жизни закэшированного заказа. Нулевое значение означает неограниченное время жизни.
+	TTL time.Duration
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// FailureCooldown - время после ошибки, в течение которого Redis считается недоступным и не используется.
+	FailureCooldown time.Duration
 }
 
 type Server struct {
 	BindAddress string
+	// AdminToken - токен, который нужно передать в заголовке Authorization: Bearer для доступа к служебным
+	// эндпоинтам /admin. Пустое значение отключает служебные эндпоинты.
+	AdminToken string
 }
 
 type NatsConnection struct {
 	URL     string
 	Subject string
 }
+
+type Cache struct {
+	// NegativeTTL - время, в течение которого хранится информация об отсутствии заказа в базе данных.
+	// Нулевое значение отключает кэширование отрицательных результатов.
+	NegativeTTL time.Duration
+	// NegativeSize - максимальное количество отрицательных результатов, хранящихся одновременно.
+	NegativeSize int
+	// ListenChanges включает сброс закэшированных заказов по уведомлениям об их изменении из Postgres.
+	ListenChanges bool
+	// SnapshotPath - путь к файлу, в который сохраняется содержимое кэша при остановке сервиса и из которого
+	// оно загружается при запуске. Пустое значение отключает сохранение снимков.
+	SnapshotPath string
+}
+
+type Retention struct {
+	// Enabled включает периодическое удаление устаревших заказов.
+	Enabled bool
+	// MaxAge - возраст заказа (по date_created), после которого он удаляется.
+	MaxAge time.Duration
+	// Archive включает сохранение заказов в таблицу orders_archive перед удалением.
+	Archive bool
+	// BatchSize - количество заказов, удаляемых одной транзакцией.
+	BatchSize int
+	// Interval - период запуска удаления.
+	Interval time.Duration
+}
+
+type Consistency struct {
+	// Enabled включает периодическую сверку закэшированных заказов с основным хранилищем.
+	Enabled bool
+	// Interval - период запуска сверки.
+	Interval time.Duration
+	// Sample - количество случайных заказов, проверяемых в каждом кэше. Нулевое значение включает проверку всех
+	// закэшированных заказов.
+	Sample int
+	// Repair включает исправление расхождений: копия заказа в кэше заменяется заказом из основного хранилища или
+	// удаляется, если его там нет.
+	Repair bool
+}
+
+type Encryption struct {
+	// KeyringPath - путь к файлу связки ключей для шифрования персональных данных. Пустое значение
+	// отключает шифрование.
+	KeyringPath string
+}
+
+type Tracing struct {
+	// Exporter - способ выгрузки трассировок: "otlp" (OTLP по HTTP), "stdout" (в стандартный вывод или файл FilePath).
+	// Пустое значение отключает трассировку.
+	Exporter string
+	// OTLPEndpoint - адрес (host:port) коллектора, принимающего трассировки по OTLP/HTTP.
+	OTLPEndpoint string
+	// OTLPInsecure отключает TLS при подключении к коллектору.
+	OTLPInsecure bool
+	// FilePath - файл, в который экспортер "stdout" дописывает трассировки. Пустое значение означает стандартный вывод.
+	FilePath string
+	// ServiceName - имя сервиса в трассировках.
+	ServiceName string
+	// SampleRatio - доля трассировок, начинающихся в сервисе, которые записываются. Для продолжаемых трассировок
+	// учитывается решение вызывающей стороны.
+	SampleRatio float64
+}
+
+type Analytics struct {
+	// CacheTTL - время, в течение которого повторные запросы показателей продаж отдаются из кэша.
+	// Нулевое значение отключает кэширование.
+	CacheTTL time.Duration
+}
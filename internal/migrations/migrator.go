@@ -0,0 +1,220 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"wb-l0/internal/config"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+)
+
+// advisoryLockKey - ключ advisory-блокировки, под которой применяются миграции. Блокировка не даёт
+// нескольким одновременно запускающимся экземплярам сервиса применять миграции параллельно.
+const advisoryLockKey int64 = 0x77626c30 // "wbl0"
+
+// Migrator применяет встроенные миграции к базе данных Postgres и хранит номера применённых версий
+// в таблице schema_migrations.
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []Migration
+}
+
+// Status - состояние одной миграции. AppliedAt равен nil, если миграция ещё не применена.
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+func NewMigrator(conn *pgx.Conn) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{conn: conn, migrations: migrations}, nil
+}
+
+// MigrateFromConfig подключается к базе данных и применяет к ней все неприменённые миграции.
+func MigrateFromConfig(ctx context.Context, cfg config.PostgresConnection) error {
+	conn, err := pgx.Connect(ctx, cfg.URL)
+	if err != nil {
+		return fmt.Errorf("error connecting to postgres: %s", err)
+	}
+	defer conn.Close(context.Background())
+
+	migrator, err := NewMigrator(conn)
+	if err != nil {
+		return err
+	}
+
+	_, err = migrator.Up(ctx)
+	return err
+}
+
+const createVersionTableQuery = `
+create table if not exists schema_migrations
+(
+    version    bigint primary key,
+    name       varchar     not null,
+    applied_at timestamptz not null default now()
+)`
+
+// Up применяет все неприменённые миграции и возвращает их количество.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func() error {
+		versions, err := m.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			err := m.apply(ctx, migration.Up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(
+					ctx, "insert into schema_migrations (version, name) values ($1, $2)",
+					migration.Version, migration.Name,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("error applying migration %d_%s: %s", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("applied migration %d_%s\n", migration.Version, migration.Name)
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down откатывает не более steps последних применённых миграций и возвращает количество откаченных.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.withLock(ctx, func() error {
+		versions, err := m.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+
+			err := m.apply(ctx, migration.Down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, "delete from schema_migrations where version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("error reverting migration %d_%s: %s", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("reverted migration %d_%s\n", migration.Version, migration.Name)
+			reverted++
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status возвращает состояние всех известных миграций.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	_, err := m.conn.Exec(ctx, createVersionTableQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error creating version table: %s", err)
+	}
+
+	versions, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int64]time.Time, error) {
+	var rows []struct {
+		Version   int64     `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+
+	err := pgxscan.Select(ctx, m.conn, &rows, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching applied migrations: %s", err)
+	}
+
+	versions := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row.AppliedAt
+	}
+
+	return versions, nil
+}
+
+// apply выполняет SQL миграции и обновление таблицы версий в одной транзакции.
+func (m *Migrator) apply(ctx context.Context, sql string, updateVersion func(tx pgx.Tx) error) error {
+	tx, err := m.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, sql)
+	if err != nil {
+		return err
+	}
+
+	err = updateVersion(tx)
+	if err != nil {
+		return fmt.Errorf("error updating version table: %s", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// withLock выполняет fn под advisory-блокировкой, предварительно создав таблицу версий.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	_, err := m.conn.Exec(ctx, "select pg_advisory_lock($1)", advisoryLockKey)
+	if err != nil {
+		return fmt.Errorf("error acquiring migration lock: %s", err)
+	}
+
+	defer func() {
+		_, err := m.conn.Exec(context.Background(), "select pg_advisory_unlock($1)", advisoryLockKey)
+		if err != nil {
+			log.Println("error releasing migration lock:", err)
+		}
+	}()
+
+	_, err = m.conn.Exec(ctx, createVersionTableQuery)
+	if err != nil {
+		return fmt.Errorf("error creating version table: %s", err)
+	}
+
+	return fn()
+}
@@ -0,0 +1,4 @@
+drop table if exists items;
+drop table if exists orders;
+drop table if exists payments;
+drop table if exists deliveries;
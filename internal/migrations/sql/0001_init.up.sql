@@ -1,21 +1,7 @@
-create table orders
-(
-    order_uid          varchar primary key,
-    track_number       varchar,
-    entry              varchar,
-    delivery_id        bigint references deliveries (id),
-    transaction        varchar references payments (transaction),
-    locale             varchar,
-    internal_signature varchar,
-    customer_id        varchar,
-    delivery_service   varchar,
-    shardkey           varchar,
-    sm_id              bigint,
-    date_created       timestamp,
-    oof_shard          varchar
-);
+-- Таблицы создаются с if not exists, чтобы миграции можно было применить к базе данных,
+-- созданной до их появления из schema.sql.
 
-create table deliveries
+create table if not exists deliveries
 (
     id      serial primary key,
     name    varchar,
@@ -27,7 +13,7 @@ create table deliveries
     email   varchar
 );
 
-create table payments
+create table if not exists payments
 (
     transaction   varchar primary key,
     request_id    varchar,
@@ -41,7 +27,24 @@ create table payments
     custom_fee    numeric(10, 2)
 );
 
-create table items
+create table if not exists orders
+(
+    order_uid          varchar primary key,
+    track_number       varchar,
+    entry              varchar,
+    delivery_id        bigint references deliveries (id),
+    transaction        varchar references payments (transaction),
+    locale             varchar,
+    internal_signature varchar,
+    customer_id        varchar,
+    delivery_service   varchar,
+    shardkey           varchar,
+    sm_id              bigint,
+    date_created       timestamp,
+    oof_shard          varchar
+);
+
+create table if not exists items
 (
     chrt_id      bigint primary key,
     track_number varchar,
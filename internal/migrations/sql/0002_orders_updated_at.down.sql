@@ -0,0 +1,4 @@
+drop index if exists orders_updated_at_idx;
+
+alter table orders
+    drop column if exists updated_at;
@@ -0,0 +1,4 @@
+alter table orders
+    add column if not exists updated_at timestamptz not null default now();
+
+create index if not exists orders_updated_at_idx on orders (updated_at);
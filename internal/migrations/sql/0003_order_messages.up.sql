@@ -0,0 +1,10 @@
+-- Исходные сообщения, из которых были получены заказы. Тело хранится в bytea, а не в jsonb,
+-- чтобы сохранить его побайтово, включая неизвестные поля и форматирование.
+create table if not exists order_messages
+(
+    order_uid   varchar primary key references orders (order_uid),
+    subject     varchar     not null,
+    headers     jsonb       not null default '{}',
+    body        bytea       not null,
+    received_at timestamptz not null
+);
@@ -0,0 +1,3 @@
+drop table if exists orders_archive;
+
+drop index if exists orders_date_created_idx;
@@ -0,0 +1,10 @@
+create index if not exists orders_date_created_idx on orders (date_created);
+
+-- Заказы, удалённые политикой хранения в режиме архивации. Заказ сохраняется целиком в виде JSON.
+create table if not exists orders_archive
+(
+    order_uid    varchar primary key,
+    date_created timestamp,
+    document     jsonb       not null,
+    archived_at  timestamptz not null default now()
+);
@@ -0,0 +1,6 @@
+-- Откат возможен только после расшифровки данных: зашифрованный телефон не помещается в varchar(11).
+alter table deliveries
+    alter column name type varchar,
+    alter column phone type varchar(11),
+    alter column address type varchar,
+    alter column email type varchar;
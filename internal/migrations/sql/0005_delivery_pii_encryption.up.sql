@@ -0,0 +1,6 @@
+-- Зашифрованные персональные данные длиннее исходных, поэтому ограничения длины снимаются.
+alter table deliveries
+    alter column name type text,
+    alter column phone type text,
+    alter column address type text,
+    alter column email type text;
@@ -0,0 +1,8 @@
+-- Каталог шардов: в каком шарде хранится заказ. Используется только в базе данных, выбранной для хранения каталога
+-- при шардировании с поиском через каталог.
+create table if not exists order_shards
+(
+    order_uid  varchar primary key,
+    shard      varchar     not null,
+    created_at timestamptz not null default now()
+);
@@ -0,0 +1,2 @@
+drop index if exists orders_customer_id_idx;
+drop table if exists order_search;
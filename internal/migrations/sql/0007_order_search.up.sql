@@ -0,0 +1,21 @@
+-- Поисковый документ заказа: названия и бренды товаров (вес A), имя получателя и город доставки (вес B).
+-- Конфигурация simple не зависит от языка, поэтому одинаково подходит для русских и латинских названий.
+create table if not exists order_search
+(
+    order_uid varchar primary key references orders (order_uid),
+    document  tsvector not null
+);
+
+create index if not exists order_search_document_idx on order_search using gin (document);
+create index if not exists orders_customer_id_idx on orders (customer_id);
+
+-- Документы для заказов, сохранённых до появления поиска. Зашифрованные имена получателей не индексируются.
+insert into order_search (order_uid, document)
+select o.order_uid,
+       setweight(to_tsvector('simple', coalesce(string_agg(concat_ws(' ', i.name, i.brand), ' '), '')), 'A') ||
+       setweight(to_tsvector('simple', concat_ws(' ', case when d.name not like 'enc:%' then d.name end, d.city)), 'B')
+from orders o
+         left join items i on i.order_uid = o.order_uid
+         left join deliveries d on d.id = o.delivery_id
+group by o.order_uid, d.name, d.city
+on conflict (order_uid) do nothing;
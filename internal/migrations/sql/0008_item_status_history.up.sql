@@ -0,0 +1,19 @@
+-- История статусов товаров. previous_status пуст у записи, соответствующей статусу при получении заказа.
+create table if not exists item_status_history
+(
+    id              bigserial primary key,
+    chrt_id         bigint      not null references items (chrt_id),
+    order_uid       varchar     not null,
+    status          int         not null,
+    previous_status int,
+    changed_at      timestamptz not null default now()
+);
+
+create index if not exists item_status_history_chrt_id_idx on item_status_history (chrt_id, changed_at);
+
+-- Исходные статусы товаров, сохранённых до появления истории.
+insert into item_status_history (chrt_id, order_uid, status, changed_at)
+select i.chrt_id, i.order_uid, i.status, coalesce(o.updated_at, now())
+from items i
+         left join orders o on o.order_uid = i.order_uid
+where not exists (select 1 from item_status_history h where h.chrt_id = i.chrt_id);
@@ -0,0 +1,7 @@
+drop table if exists order_state_transitions;
+
+drop index if exists orders_state_idx;
+
+alter table orders
+    drop column if exists state_changed_at,
+    drop column if exists state;
@@ -0,0 +1,23 @@
+-- Состояние заказа в его жизненном цикле и история его смены.
+alter table orders
+    add column if not exists state            varchar     not null default 'created',
+    add column if not exists state_changed_at timestamptz not null default now();
+
+create index if not exists orders_state_idx on orders (state);
+
+create table if not exists order_state_transitions
+(
+    id         bigserial primary key,
+    order_uid  varchar     not null references orders (order_uid),
+    from_state varchar,
+    to_state   varchar     not null,
+    changed_at timestamptz not null
+);
+
+create index if not exists order_state_transitions_order_uid_idx on order_state_transitions (order_uid, changed_at);
+
+-- Заказы, сохранённые до появления состояний, считаются только что созданными.
+insert into order_state_transitions (order_uid, to_state, changed_at)
+select o.order_uid, o.state, o.state_changed_at
+from orders o
+where not exists (select 1 from order_state_transitions t where t.order_uid = o.order_uid);
@@ -0,0 +1,3 @@
+-- Версия заказа для оптимистичной блокировки: увеличивается при каждом изменении заказа.
+alter table orders
+    add column if not exists version bigint not null default 1;
@@ -0,0 +1 @@
+drop index if exists orders_listing_idx;
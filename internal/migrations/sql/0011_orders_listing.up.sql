@@ -0,0 +1,2 @@
+-- Индекс для постраничной выдачи списка заказов от новых к старым (см. order.Cursor).
+create index if not exists orders_listing_idx on orders (date_created desc, order_uid desc);
@@ -0,0 +1,88 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"wb-l0/pkg/money"
+)
+
+// Dimension - признак, по которому группируются показатели продаж.
+type Dimension string
+
+const (
+	DimensionDay             Dimension = "day"
+	DimensionWeek            Dimension = "week"
+	DimensionBrand           Dimension = "brand"
+	DimensionDeliveryService Dimension = "delivery_service"
+	DimensionRegion          Dimension = "region"
+	DimensionCurrency        Dimension = "currency"
+)
+
+// SalesQuery - запрос показателей продаж по заказам, созданным в полуинтервале [From, To).
+type SalesQuery struct {
+	From    time.Time
+	To      time.Time
+	GroupBy []Dimension
+}
+
+func (q SalesQuery) Validate() error {
+	if !q.From.Before(q.To) {
+		return fmt.Errorf("time range is empty")
+	}
+
+	seen := make(map[Dimension]bool)
+	for _, dimension := range q.GroupBy {
+		switch dimension {
+		case DimensionDay, DimensionWeek, DimensionBrand, DimensionDeliveryService, DimensionRegion, DimensionCurrency:
+		default:
+			return fmt.Errorf("unknown dimension \"%s\"", dimension)
+		}
+
+		if seen[dimension] {
+			return fmt.Errorf("dimension \"%s\" is specified twice", dimension)
+		}
+		seen[dimension] = true
+	}
+
+	if seen[DimensionDay] && seen[DimensionWeek] {
+		return fmt.Errorf("sales cannot be grouped by day and week at the same time")
+	}
+
+	return nil
+}
+
+// Has проверяет, группируются ли показатели по признаку dimension.
+func (q SalesQuery) Has(dimension Dimension) bool {
+	for _, d := range q.GroupBy {
+		if d == dimension {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SalesRow - показатели продаж одной группы. Поля признаков, по которым не выполнялась группировка, пусты.
+// Суммы в разных валютах не складываются, поэтому показатели всегда разбиты по валюте.
+//
+// При группировке по бренду выручкой считается стоимость товаров этого бренда, а количеством заказов и
+// стоимостью доставки - количество и стоимость доставки заказов, в которых есть такие товары.
+type SalesRow struct {
+	Period          *time.Time `json:"period,omitempty"`
+	Brand           *string    `json:"brand,omitempty"`
+	DeliveryService *string    `json:"delivery_service,omitempty"`
+	Region          *string    `json:"region,omitempty"`
+	Currency        string     `json:"currency"`
+
+	OrderCount    int64        `json:"order_count"`
+	Revenue       money.Amount `json:"revenue"`
+	AverageBasket money.Amount `json:"average_basket"`
+	DeliveryCost  money.Amount `json:"delivery_cost"`
+}
+
+// Analytics - хранилище, поддерживающее расчёт показателей продаж.
+type Analytics interface {
+	Sales(ctx context.Context, query SalesQuery) ([]*SalesRow, error)
+}
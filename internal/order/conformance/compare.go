@@ -0,0 +1,93 @@
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/money"
+)
+
+// SampleOrder возвращает корректный заказ с заданным UID, в котором заполнены все поля, передаваемые
+// отправителем. Идентификаторы товаров начинаются с chrtID.
+func SampleOrder(uid string, chrtID int64) *order.Order {
+	created := time.Date(2021, time.November, 26, 6, 22, 19, 123456000, time.UTC)
+	return &order.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: &order.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: &order.Payment{
+			Transaction:  uid,
+			RequestID:    "request-1",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       money.MustParse("1817.50"),
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: money.MustParse("1500"),
+			GoodsTotal:   money.MustParse("317.25"),
+			CustomFee:    money.MustParse("0.25"),
+		},
+		Items: []*order.Item{
+			{
+				ChrtID:      chrtID,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       money.MustParse("453"),
+				RID:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  money.MustParse("317.10"),
+				NmID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+			{
+				ChrtID:      chrtID + 1,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       money.MustParse("0.15"),
+				RID:         "ab4219087a764ae0btest2",
+				Name:        "Brush",
+				Sale:        12.5,
+				Size:        "M",
+				TotalPrice:  money.MustParse("0.15"),
+				NmID:        2389213,
+				Brand:       "Nike",
+				Status:      203,
+			},
+		},
+		Locale:            "en",
+		InternalSignature: "signature",
+		CustomerID:        "test",
+		DeliveryService:   "meest",
+		ShardKey:          "9",
+		SmID:              99,
+		DateCreated:       created,
+		OofShard:          "1",
+	}
+}
+
+// compareOrders сравнивает заказы (см. order.Diff) и возвращает ошибку, перечисляющую все различающиеся поля.
+func compareOrders(want, got *order.Order) error {
+	differences, err := order.Diff(want, got)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, difference := range differences {
+		errs = append(errs, fmt.Errorf("%s: got %v, want %v", difference.Path, difference.Got, difference.Want))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,243 @@
+// Package conformance проверяет, что реализация order.Repository соблюдает общий для всех реализаций контракт:
+// сохраняет все поля заказа, одинаково сообщает об отсутствии и повторном сохранении заказа и корректно работает
+// при одновременных обращениях.
+//
+// Проверки сохраняют заказы с уникальными UID и не удаляют их, поэтому запускать их следует на временной базе
+// данных (см. пакет pgtest).
+package conformance
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wb-l0/internal/order"
+)
+
+// Options - особенности проверяемой реализации.
+type Options struct {
+	// Cache означает, что репозиторий является кэшем: повторное сохранение заказа перезаписывает его,
+	// а не возвращает order.ErrAlreadyExists.
+	Cache bool
+	// Concurrency - количество одновременных обращений при проверках параллельной работы. По умолчанию 16.
+	Concurrency int
+}
+
+// Run выполняет проверки репозитория, каждую - в отдельном подтесте t.
+func Run(t *testing.T, repository order.Repository, opts Options) {
+	t.Helper()
+
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 16
+	}
+
+	ctx := context.Background()
+	c := &checker{repository: repository, opts: opts, prefix: randomPrefix()}
+	checks := []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{"round trip", c.checkRoundTrip},
+		{"not found", c.checkNotFound},
+		{"create after not found", c.checkCreateAfterNotFound},
+		{"duplicate", c.checkDuplicate},
+		{"concurrent access", c.checkConcurrentAccess},
+		{"concurrent duplicates", c.checkConcurrentDuplicates},
+	}
+
+	for _, check := range checks {
+		t.Run(check.name, func(t *testing.T) {
+			err := check.fn(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+type checker struct {
+	repository order.Repository
+	opts       Options
+	prefix     string
+	sequence   atomic.Int64
+}
+
+// nextOrder возвращает новый заказ с уникальными UID, транзакцией и идентификаторами товаров.
+func (c *checker) nextOrder() *order.Order {
+	n := c.sequence.Add(1)
+	return SampleOrder(fmt.Sprintf("%s-%d", c.prefix, n), chrtIDBase+n*10)
+}
+
+// chrtIDBase - начало диапазона идентификаторов товаров проверочных заказов. chrt_id уникален среди всех
+// товаров, поэтому диапазон выбирается случайно при каждом запуске.
+var chrtIDBase = time.Now().UnixMicro() * 1000
+
+func (c *checker) checkRoundTrip(ctx context.Context) error {
+	o := c.nextOrder()
+	err := c.repository.CreateOrder(ctx, o)
+	if err != nil {
+		return fmt.Errorf("error creating order: %s", err)
+	}
+
+	got, err := c.repository.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		return fmt.Errorf("error getting order: %s", err)
+	}
+
+	// Поля, которые заполняет сам репозиторий (состояние, версия), сравниваются с заказом после сохранения.
+	return compareOrders(o, got)
+}
+
+func (c *checker) checkNotFound(ctx context.Context) error {
+	o, err := c.repository.GetOrder(ctx, c.prefix+"-missing")
+	if !errors.Is(err, order.ErrNotFound) {
+		return fmt.Errorf("got error %v, want order.ErrNotFound", err)
+	}
+
+	if o != nil {
+		return errors.New("order is returned along with order.ErrNotFound")
+	}
+
+	return nil
+}
+
+// checkCreateAfterNotFound проверяет, что сохранённый заказ виден сразу же, даже если перед этим его
+// безуспешно искали, например, при кэшировании отсутствия заказов.
+func (c *checker) checkCreateAfterNotFound(ctx context.Context) error {
+	o := c.nextOrder()
+	_, err := c.repository.GetOrder(ctx, o.OrderUID)
+	if !errors.Is(err, order.ErrNotFound) {
+		return fmt.Errorf("got error %v before creating order, want order.ErrNotFound", err)
+	}
+
+	err = c.repository.CreateOrder(ctx, o)
+	if err != nil {
+		return fmt.Errorf("error creating order: %s", err)
+	}
+
+	_, err = c.repository.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		return fmt.Errorf("error getting order after creating it: %s", err)
+	}
+
+	return nil
+}
+
+func (c *checker) checkDuplicate(ctx context.Context) error {
+	o := c.nextOrder()
+	err := c.repository.CreateOrder(ctx, o)
+	if err != nil {
+		return fmt.Errorf("error creating order: %s", err)
+	}
+
+	duplicate := SampleOrder(o.OrderUID, o.Items[0].ChrtID)
+	duplicate.TrackNumber = "DUPLICATE"
+	err = c.repository.CreateOrder(ctx, duplicate)
+
+	want := o
+	if c.opts.Cache {
+		if err != nil {
+			return fmt.Errorf("error overwriting cached order: %s", err)
+		}
+
+		want = duplicate
+	} else if !errors.Is(err, order.ErrAlreadyExists) {
+		return fmt.Errorf("got error %v when creating order twice, want order.ErrAlreadyExists", err)
+	}
+
+	got, err := c.repository.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		return fmt.Errorf("error getting order: %s", err)
+	}
+
+	if got.TrackNumber != want.TrackNumber {
+		return fmt.Errorf("got track number %q after creating order twice, want %q", got.TrackNumber, want.TrackNumber)
+	}
+
+	return nil
+}
+
+// checkConcurrentAccess одновременно сохраняет и читает разные заказы.
+func (c *checker) checkConcurrentAccess(ctx context.Context) error {
+	return c.parallel(func(int) error {
+		o := c.nextOrder()
+		err := c.repository.CreateOrder(ctx, o)
+		if err != nil {
+			return fmt.Errorf("error creating order %s: %s", o.OrderUID, err)
+		}
+
+		got, err := c.repository.GetOrder(ctx, o.OrderUID)
+		if err != nil {
+			return fmt.Errorf("error getting order %s: %s", o.OrderUID, err)
+		}
+
+		if got.OrderUID != o.OrderUID {
+			return fmt.Errorf("got order %s instead of %s", got.OrderUID, o.OrderUID)
+		}
+
+		return nil
+	})
+}
+
+// checkConcurrentDuplicates одновременно сохраняет один и тот же заказ: сохранение должно удаться ровно
+// один раз, если репозиторий не является кэшем.
+func (c *checker) checkConcurrentDuplicates(ctx context.Context) error {
+	template := c.nextOrder()
+	var created atomic.Int64
+	err := c.parallel(func(int) error {
+		o := SampleOrder(template.OrderUID, template.Items[0].ChrtID)
+		err := c.repository.CreateOrder(ctx, o)
+		if err == nil {
+			created.Add(1)
+			return nil
+		}
+
+		if !c.opts.Cache && errors.Is(err, order.ErrAlreadyExists) {
+			return nil
+		}
+
+		return fmt.Errorf("unexpected error creating order: %s", err)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !c.opts.Cache && created.Load() != 1 {
+		return fmt.Errorf("order is created %d times", created.Load())
+	}
+
+	_, err = c.repository.GetOrder(ctx, template.OrderUID)
+	if err != nil {
+		return fmt.Errorf("error getting order: %s", err)
+	}
+
+	return nil
+}
+
+// parallel выполняет fn в Concurrency горутинах и возвращает все их ошибки.
+func (c *checker) parallel(fn func(i int) error) error {
+	errs := make([]error, c.opts.Concurrency)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func randomPrefix() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "conformance-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,293 @@
+// Package consistency сверяет закэшированные заказы с основным хранилищем: ошибки записи в кэш и потерянные
+// уведомления об изменениях могут оставить в кэше устаревшие копии заказов.
+package consistency
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+)
+
+// maxReportedMismatches - максимальное количество расхождений, перечисляемых в отчёте. Остальные только
+// учитываются в MismatchCount.
+const maxReportedMismatches = 100
+
+// Cache - кэш, закэшированные заказы которого можно перебрать.
+type Cache interface {
+	order.Cache
+	// ScanOrderUIDs передаёт fn UID закэшированных заказов: всех или, если limit больше нуля, не более limit.
+	ScanOrderUIDs(ctx context.Context, limit int, fn func(uid string) error) error
+}
+
+// Target - сверяемый кэш.
+type Target struct {
+	Name  string
+	Cache Cache
+}
+
+// Options - параметры сверки.
+type Options struct {
+	// Sample - количество проверяемых заказов в каждом кэше, 0 - все закэшированные заказы.
+	Sample int
+	// Repair включает исправление найденных расхождений.
+	Repair bool
+}
+
+// MismatchReason - вид расхождения между кэшем и основным хранилищем.
+type MismatchReason string
+
+const (
+	// ReasonMissingInDatabase - заказ есть в кэше, но отсутствует в основном хранилище.
+	ReasonMissingInDatabase MismatchReason = "missing_in_database"
+	// ReasonFieldsDiffer - поля закэшированного заказа отличаются от сохранённых.
+	ReasonFieldsDiffer MismatchReason = "fields_differ"
+)
+
+// Mismatch - расхождение закэшированного заказа с основным хранилищем.
+type Mismatch struct {
+	OrderUID string         `json:"order_uid"`
+	Reason   MismatchReason `json:"reason"`
+	// Fields - пути различающихся полей в JSON (см. order.Diff).
+	Fields   []string `json:"fields,omitempty"`
+	Repaired bool     `json:"repaired"`
+}
+
+// Report - результат сверки одного кэша.
+type Report struct {
+	Cache      string    `json:"cache"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Checked - количество проверенных заказов, Errors - количество заказов, которые не удалось проверить.
+	Checked       int `json:"checked"`
+	Errors        int `json:"errors"`
+	MismatchCount int `json:"mismatch_count"`
+	Repaired      int `json:"repaired"`
+	// Mismatches - первые maxReportedMismatches найденных расхождений.
+	Mismatches []*Mismatch `json:"mismatches"`
+}
+
+// Checker сверяет закэшированные заказы с основным хранилищем. Для каждого заказа из кэша заказ читается
+// из хранилища и сравнивается с копией по всем полям. Заказы, которые есть в хранилище, но отсутствуют в кэше,
+// расхождением не считаются.
+type Checker struct {
+	database order.Repository
+	targets  []Target
+	cfg      config.Consistency
+
+	mu   sync.Mutex
+	last []*Report
+}
+
+func NewChecker(database order.Repository, targets []Target, cfg config.Consistency) *Checker {
+	return &Checker{database: database, targets: targets, cfg: cfg}
+}
+
+// Run запускает сверку с параметрами из конфигурации сразу и затем с заданным периодом до отмены контекста.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		reports, err := c.Check(ctx, Options{Sample: c.cfg.Sample, Repair: c.cfg.Repair})
+		if err != nil && ctx.Err() == nil {
+			log.Println("error checking cache consistency:", err)
+		}
+
+		for _, report := range reports {
+			if report.MismatchCount > 0 || report.Errors > 0 {
+				log.Printf(
+					"cache %s: checked %d orders, found %d mismatches, repaired %d, failed to check %d\n",
+					report.Cache, report.Checked, report.MismatchCount, report.Repaired, report.Errors,
+				)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check сверяет все кэши и возвращает отчёты о них. Ошибка возвращается, только если не удалось перебрать
+// заказы в кэше; отчёты о уже проверенных кэшах при этом тоже возвращаются.
+func (c *Checker) Check(ctx context.Context, opts Options) ([]*Report, error) {
+	var reports []*Report
+	for _, target := range c.targets {
+		report, err := c.checkTarget(ctx, target, opts)
+		reports = append(reports, report)
+		if err != nil {
+			return reports, err
+		}
+	}
+
+	c.mu.Lock()
+	c.last = reports
+	c.mu.Unlock()
+
+	return reports, nil
+}
+
+// LastReports возвращает отчёты последней завершённой сверки или nil, если сверок ещё не было.
+func (c *Checker) LastReports() []*Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.last
+}
+
+func (c *Checker) checkTarget(ctx context.Context, target Target, opts Options) (*Report, error) {
+	report := &Report{Cache: target.Name, StartedAt: time.Now(), Mismatches: []*Mismatch{}}
+	err := target.Cache.ScanOrderUIDs(ctx, opts.Sample, func(uid string) error {
+		mismatch, checked, err := c.checkOrder(ctx, target, uid, opts.Repair)
+		if err != nil {
+			// Отмена сверки прекращает перебор, остальные ошибки касаются только одного заказа.
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			log.Printf("error checking order %s in cache %s: %s\n", uid, target.Name, err)
+			report.Errors++
+			return nil
+		}
+
+		if !checked {
+			return nil
+		}
+
+		report.Checked++
+		ordersChecked.WithLabelValues(target.Name).Inc()
+		if mismatch == nil {
+			return nil
+		}
+
+		report.MismatchCount++
+		mismatchesFound.WithLabelValues(target.Name, string(mismatch.Reason)).Inc()
+		if mismatch.Repaired {
+			report.Repaired++
+		}
+
+		if len(report.Mismatches) < maxReportedMismatches {
+			report.Mismatches = append(report.Mismatches, mismatch)
+		}
+
+		return nil
+	})
+
+	report.FinishedAt = time.Now()
+	return report, err
+}
+
+// checkOrder сверяет закэшированный заказ с хранилищем. checked равен false, если заказ исчез из кэша
+// до завершения проверки: например, истёк срок его хранения или он был сброшен после изменения.
+func (c *Checker) checkOrder(
+	ctx context.Context, target Target, uid string, repair bool,
+) (mismatch *Mismatch, checked bool, err error) {
+	cached, err := target.Cache.GetOrder(ctx, uid)
+	if err != nil {
+		if errors.Is(err, order.ErrNotFound) {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	stored, err := c.database.GetOrder(ctx, uid)
+	if err != nil && !errors.Is(err, order.ErrNotFound) {
+		return nil, false, err
+	}
+
+	mismatch, err = compare(uid, stored, cached)
+	if err != nil || mismatch == nil {
+		return nil, err == nil, err
+	}
+
+	// Заказ мог измениться между чтением копии и чтением из хранилища, тогда копия к этому моменту уже сброшена
+	// или заменена новой.
+	cached, err = target.Cache.GetOrder(ctx, uid)
+	if err != nil {
+		if errors.Is(err, order.ErrNotFound) {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	mismatch, err = compare(uid, stored, cached)
+	if err != nil || mismatch == nil {
+		return nil, err == nil, err
+	}
+
+	if repair {
+		err = c.repair(ctx, target, uid)
+		if err != nil {
+			log.Printf("error repairing order %s in cache %s: %s\n", uid, target.Name, err)
+		}
+
+		mismatch.Repaired = err == nil
+	}
+
+	return mismatch, true, nil
+}
+
+// compare сравнивает копию заказа с заказом из хранилища; stored равен nil, если заказа в хранилище нет.
+func compare(uid string, stored, cached *order.Order) (*Mismatch, error) {
+	if stored == nil {
+		return &Mismatch{OrderUID: uid, Reason: ReasonMissingInDatabase}, nil
+	}
+
+	differences, err := order.Diff(stored, cached)
+	if err != nil || len(differences) == 0 {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(differences))
+	for _, difference := range differences {
+		fields = append(fields, difference.Path)
+	}
+
+	return &Mismatch{OrderUID: uid, Reason: ReasonFieldsDiffer, Fields: fields}, nil
+}
+
+// repair заменяет копию заказа в кэше заказом из хранилища или удаляет её, если заказа в хранилище нет.
+//
+// Заказ перечитывается из хранилища уже после удаления копии: прочитанный при сверке заказ мог с тех пор
+// измениться, а уведомление об изменении - прийти раньше, чем копия будет записана. Если после записи версия
+// заказа в хранилище отличается от записанной, копия снова удаляется и будет заполнена при следующем чтении.
+func (c *Checker) repair(ctx context.Context, target Target, uid string) error {
+	err := target.Cache.DeleteOrder(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	stored, err := c.database.GetOrder(ctx, uid)
+	if err != nil {
+		if errors.Is(err, order.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	err = target.Cache.CreateOrder(ctx, stored)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.database.GetOrder(ctx, uid)
+	if err == nil && current.Version == stored.Version {
+		return nil
+	}
+
+	if err != nil && !errors.Is(err, order.ErrNotFound) {
+		log.Printf("error re-reading order %s after repairing cache %s: %s\n", uid, target.Name, err)
+	}
+
+	return target.Cache.DeleteOrder(ctx, uid)
+}
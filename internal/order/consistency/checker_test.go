@@ -0,0 +1,244 @@
+package consistency
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/conformance"
+	"wb-l0/internal/order/repository"
+)
+
+// newTestChecker создаёт сверку кэша cache с хранилищем database.
+func newTestChecker(database order.Repository, cache Cache) *Checker {
+	return NewChecker(database, []Target{{Name: "test", Cache: cache}}, config.Consistency{})
+}
+
+// createOrders сохраняет заказы в репозитории и завершает тест при ошибке.
+func createOrders(t *testing.T, repo order.Repository, orders ...*order.Order) {
+	t.Helper()
+
+	for _, o := range orders {
+		err := repo.CreateOrder(context.Background(), o)
+		if err != nil {
+			t.Fatalf("error creating order %s: %s", o.OrderUID, err)
+		}
+	}
+}
+
+// checkOne выполняет сверку и возвращает отчёт о единственном кэше.
+func checkOne(t *testing.T, checker *Checker, opts Options) *Report {
+	t.Helper()
+
+	reports, err := checker.Check(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("error checking consistency: %s", err)
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+
+	return reports[0]
+}
+
+// withVersion возвращает копию заказа o с версией version и номером отслеживания track.
+func withVersion(o *order.Order, version int64, track string) *order.Order {
+	changed := *o
+	changed.Version = version
+	changed.TrackNumber = track
+	return &changed
+}
+
+func TestCheckConsistentOrders(t *testing.T) {
+	database, cache := repository.NewInMemoryRepository(), repository.NewInMemoryRepository()
+	o := conformance.SampleOrder("consistent", 1)
+	createOrders(t, database, o)
+	createOrders(t, cache, o)
+
+	report := checkOne(t, newTestChecker(database, cache), Options{Repair: true})
+	if report.Checked != 1 || report.MismatchCount != 0 || report.Errors != 0 || len(report.Mismatches) != 0 {
+		t.Fatalf("got report %+v, want one consistent order", report)
+	}
+}
+
+func TestCheckMissingInDatabase(t *testing.T) {
+	for _, repair := range []bool{false, true} {
+		t.Run(fmt.Sprintf("repair=%t", repair), func(t *testing.T) {
+			database, cache := repository.NewInMemoryRepository(), repository.NewInMemoryRepository()
+			createOrders(t, cache, conformance.SampleOrder("missing", 1))
+
+			report := checkOne(t, newTestChecker(database, cache), Options{Repair: repair})
+			if report.Checked != 1 || report.MismatchCount != 1 || len(report.Mismatches) != 1 {
+				t.Fatalf("got report %+v, want one mismatch", report)
+			}
+
+			mismatch := report.Mismatches[0]
+			if mismatch.OrderUID != "missing" || mismatch.Reason != ReasonMissingInDatabase ||
+				mismatch.Repaired != repair || len(mismatch.Fields) != 0 {
+				t.Errorf("got mismatch %+v", mismatch)
+			}
+
+			_, err := cache.GetOrder(context.Background(), "missing")
+			if repair && err != order.ErrNotFound {
+				t.Errorf("repaired order is left in cache: %v", err)
+			}
+			if !repair && err != nil {
+				t.Errorf("order is removed from cache without repair: %s", err)
+			}
+		})
+	}
+}
+
+func TestCheckFieldsDiffer(t *testing.T) {
+	for _, repair := range []bool{false, true} {
+		t.Run(fmt.Sprintf("repair=%t", repair), func(t *testing.T) {
+			database, cache := repository.NewInMemoryRepository(), repository.NewInMemoryRepository()
+			stored := withVersion(conformance.SampleOrder("differ", 1), 2, "NEWTRACK")
+			createOrders(t, database, stored)
+			createOrders(t, cache, withVersion(stored, 1, "OLDTRACK"))
+
+			report := checkOne(t, newTestChecker(database, cache), Options{Repair: repair})
+			if report.Checked != 1 || report.MismatchCount != 1 || len(report.Mismatches) != 1 {
+				t.Fatalf("got report %+v, want one mismatch", report)
+			}
+
+			if repair && report.Repaired != 1 {
+				t.Errorf("got %d repaired orders, want 1", report.Repaired)
+			}
+
+			mismatch := report.Mismatches[0]
+			if mismatch.Reason != ReasonFieldsDiffer || mismatch.Repaired != repair {
+				t.Errorf("got mismatch %+v", mismatch)
+			}
+
+			if fmt.Sprint(mismatch.Fields) != "[track_number version]" {
+				t.Errorf("got fields %v, want [track_number version]", mismatch.Fields)
+			}
+
+			cached, err := cache.GetOrder(context.Background(), "differ")
+			if err != nil {
+				t.Fatalf("error reading cached order: %s", err)
+			}
+
+			want := "OLDTRACK"
+			if repair {
+				want = "NEWTRACK"
+			}
+			if cached.TrackNumber != want {
+				t.Errorf("got cached track number %s, want %s", cached.TrackNumber, want)
+			}
+		})
+	}
+}
+
+func TestCheckSample(t *testing.T) {
+	database, cache := repository.NewInMemoryRepository(), repository.NewInMemoryRepository()
+	for i := 0; i < 5; i++ {
+		createOrders(t, cache, conformance.SampleOrder(fmt.Sprintf("sample-%d", i), int64(i+1)))
+	}
+
+	checker := newTestChecker(database, cache)
+	if report := checkOne(t, checker, Options{Sample: 2}); report.Checked != 2 || report.MismatchCount != 2 {
+		t.Errorf("got report %+v, want 2 checked orders", report)
+	}
+
+	if report := checkOne(t, checker, Options{}); report.Checked != 5 || report.MismatchCount != 5 {
+		t.Errorf("got report %+v, want all 5 orders checked", report)
+	}
+}
+
+func TestCheckReportsLimitedMismatches(t *testing.T) {
+	database, cache := repository.NewInMemoryRepository(), repository.NewInMemoryRepository()
+	count := maxReportedMismatches + 5
+	for i := 0; i < count; i++ {
+		createOrders(t, cache, conformance.SampleOrder(fmt.Sprintf("limit-%d", i), int64(i+1)))
+	}
+
+	report := checkOne(t, newTestChecker(database, cache), Options{})
+	if report.MismatchCount != count || len(report.Mismatches) != maxReportedMismatches {
+		t.Errorf("got %d mismatches with %d reported, want %d with %d reported",
+			report.MismatchCount, len(report.Mismatches), count, maxReportedMismatches)
+	}
+}
+
+// changingRepository - хранилище, в котором перед n-м чтением заказа (начиная с 1) выполняется change.
+type changingRepository struct {
+	*repository.InMemoryRepository
+	reads  int
+	before map[int]func()
+}
+
+func (r *changingRepository) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
+	r.reads++
+	if change, ok := r.before[r.reads]; ok {
+		change()
+	}
+
+	return r.InMemoryRepository.GetOrder(ctx, uid)
+}
+
+// replaceOrder заменяет заказ в репозитории repo.
+func replaceOrder(t *testing.T, repo *repository.InMemoryRepository, o *order.Order) {
+	t.Helper()
+
+	err := repo.DeleteOrder(context.Background(), o.OrderUID)
+	if err == nil {
+		err = repo.CreateOrder(context.Background(), o)
+	}
+	if err != nil {
+		t.Fatalf("error replacing order %s: %s", o.OrderUID, err)
+	}
+}
+
+func TestRepairRereadsChangedOrder(t *testing.T) {
+	database := &changingRepository{InMemoryRepository: repository.NewInMemoryRepository()}
+	cache := repository.NewInMemoryRepository()
+	stored := withVersion(conformance.SampleOrder("reread", 1), 2, "SECOND")
+	createOrders(t, database, stored)
+	createOrders(t, cache, withVersion(stored, 1, "FIRST"))
+
+	// Заказ меняется после сверки, но до исправления: в кэш должна попасть новая версия, а не сверенная.
+	database.before = map[int]func(){2: func() {
+		replaceOrder(t, database.InMemoryRepository, withVersion(stored, 3, "THIRD"))
+	}}
+
+	report := checkOne(t, newTestChecker(database, cache), Options{Repair: true})
+	if report.Repaired != 1 {
+		t.Fatalf("got report %+v, want one repaired order", report)
+	}
+
+	cached, err := cache.GetOrder(context.Background(), "reread")
+	if err != nil {
+		t.Fatalf("error reading cached order: %s", err)
+	}
+
+	if cached.Version != 3 || cached.TrackNumber != "THIRD" {
+		t.Errorf("got cached version %d (%s), want 3 (THIRD)", cached.Version, cached.TrackNumber)
+	}
+}
+
+func TestRepairDropsOrderChangedDuringRepair(t *testing.T) {
+	database := &changingRepository{InMemoryRepository: repository.NewInMemoryRepository()}
+	cache := repository.NewInMemoryRepository()
+	stored := withVersion(conformance.SampleOrder("during", 1), 2, "SECOND")
+	createOrders(t, database, stored)
+	createOrders(t, cache, withVersion(stored, 1, "FIRST"))
+
+	// Заказ меняется после записи исправленной копии: копия устарела и должна быть удалена.
+	database.before = map[int]func(){3: func() {
+		replaceOrder(t, database.InMemoryRepository, withVersion(stored, 3, "THIRD"))
+	}}
+
+	report := checkOne(t, newTestChecker(database, cache), Options{Repair: true})
+	if report.Repaired != 1 {
+		t.Fatalf("got report %+v, want one repaired order", report)
+	}
+
+	_, err := cache.GetOrder(context.Background(), "during")
+	if err != order.ErrNotFound {
+		t.Errorf("stale copy is left in cache: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package consistency
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ordersChecked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "consistency",
+		Name:      "orders_checked_total",
+		Help:      "Number of cached orders compared with the primary storage.",
+	}, []string{"cache"})
+
+	mismatchesFound = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "consistency",
+		Name:      "mismatches_total",
+		Help:      "Number of cached orders that differ from the primary storage.",
+	}, []string{"cache", "reason"})
+)
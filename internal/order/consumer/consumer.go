@@ -3,15 +3,24 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"wb-l0/internal/config"
 	"wb-l0/internal/order"
 
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("wb-l0/internal/order/consumer")
+
 type Consumer struct {
 	conn    *nats.Conn
 	subject string
@@ -47,7 +56,10 @@ func (c *Consumer) Subscribe(ctx context.Context) error {
 func (c *Consumer) wrappedMessageHandler(ctx context.Context) func(msg *nats.Msg) {
 	return func(msg *nats.Msg) {
 		log.Printf("received message with length of %d bytes\n", len(msg.Data))
-		err := c.handleMessage(ctx, msg)
+		messagesReceived.Inc()
+		bytesReceived.Add(float64(len(msg.Data)))
+
+		err := c.handleMessage(ctx, msg, time.Now())
 		if err != nil {
 			log.Printf("discarding message due to error: %s", err)
 			return
@@ -55,25 +67,60 @@ func (c *Consumer) wrappedMessageHandler(ctx context.Context) func(msg *nats.Msg
 	}
 }
 
-func (c *Consumer) handleMessage(ctx context.Context, msg *nats.Msg) error {
+func (c *Consumer) handleMessage(ctx context.Context, msg *nats.Msg, receivedAt time.Time) (err error) {
+	// Продолжаем трассировку отправителя, если он передал её контекст в заголовках сообщения.
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier(msg.Header))
+	ctx, span := tracer.Start(ctx, msg.Subject+" process", trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		semconv.MessagingSystemKey.String("nats"),
+		semconv.MessagingDestinationName(msg.Subject),
+		semconv.MessagingMessagePayloadSizeBytes(len(msg.Data)),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	var o order.Order
-	err := json.Unmarshal(msg.Data, &o)
+	err = json.Unmarshal(msg.Data, &o)
 	if err != nil {
+		decodeFailures.Inc()
 		return fmt.Errorf("error unmarshalling message: %s\n", err)
 	}
 
+	span.SetAttributes(attribute.String("order.uid", o.OrderUID))
+
 	err = o.Validate()
 	if err != nil {
+		validationFailures.Inc()
 		log.Printf("message has failed validation: %s\n", err)
 		return err
 	}
 
-	err = c.orderRepository.CreateOrder(ctx, &o)
+	// Сохраняем исходное сообщение вместе с заказом, чтобы его можно было поднять при разборе спорных ситуаций.
+	message := &order.RawMessage{
+		Subject:    msg.Subject,
+		Headers:    msg.Header,
+		Body:       msg.Data,
+		ReceivedAt: receivedAt,
+	}
+
+	err = order.CreateOrderWithMessage(ctx, c.orderRepository, &o, message)
+	if errors.Is(err, order.ErrAlreadyExists) {
+		// Повторная доставка уже сохранённого заказа не считается ошибкой.
+		duplicateOrders.Inc()
+		log.Println("skipping already saved order with UID", o.OrderUID)
+		return nil
+	}
+
 	if err != nil {
 		log.Printf("error saving message: %s\n", err)
 		return err
 	}
 
+	ordersPersisted.Inc()
 	log.Println("saved new order with UID", o.OrderUID)
 	return nil
 }
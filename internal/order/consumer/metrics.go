@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	messagesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "consumer",
+		Name:      "messages_received_total",
+		Help:      "Number of messages received from NATS.",
+	})
+
+	bytesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "consumer",
+		Name:      "received_bytes_total",
+		Help:      "Total size of message bodies received from NATS.",
+	})
+
+	decodeFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "consumer",
+		Name:      "decode_failures_total",
+		Help:      "Number of messages that could not be decoded as an order.",
+	})
+
+	validationFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "consumer",
+		Name:      "validation_failures_total",
+		Help:      "Number of decoded orders that failed validation.",
+	})
+
+	duplicateOrders = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "consumer",
+		Name:      "duplicate_orders_total",
+		Help:      "Number of decoded orders that were already saved to the repository.",
+	})
+
+	ordersPersisted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "consumer",
+		Name:      "orders_persisted_total",
+		Help:      "Number of orders successfully saved to the repository.",
+	})
+)
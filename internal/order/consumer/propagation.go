@@ -0,0 +1,39 @@
+package consumer
+
+import (
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// headerCarrier позволяет читать контекст трассировки из заголовков сообщения NATS. В отличие от HTTP, имена
+// заголовков в NATS чувствительны к регистру, а отправители записывают их по-разному (traceparent, Traceparent),
+// поэтому поиск выполняется без учёта регистра.
+type headerCarrier nats.Header
+
+func (c headerCarrier) Get(key string) string {
+	if values, ok := c[key]; ok && len(values) > 0 {
+		return values[0]
+	}
+
+	for name, values := range c {
+		if strings.EqualFold(name, key) && len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/httperrors"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminHandler - обработчики служебных запросов, не предназначенных для клиентов сервиса.
+type AdminHandler struct {
+	rawMessages order.RawMessageRepository
+}
+
+func NewAdminHandler(rawMessages order.RawMessageRepository) *AdminHandler {
+	return &AdminHandler{rawMessages: rawMessages}
+}
+
+// GetRawMessage возвращает исходное сообщение, из которого был получен заказ. Тело сообщения
+// отдаётся в base64, чтобы сохранить его побайтово.
+func (h *AdminHandler) GetRawMessage(r *http.Request) (any, error) {
+	orderId := chi.URLParam(r, "id")
+	message, err := h.rawMessages.GetRawMessage(r.Context(), orderId)
+	if err != nil {
+		if err == order.ErrNotFound {
+			return nil, httperrors.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return message, nil
+}
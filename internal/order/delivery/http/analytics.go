@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/httperrors"
+)
+
+// defaultSalesPeriod - период, за который считаются показатели продаж, если начало периода не указано.
+const defaultSalesPeriod = 30 * 24 * time.Hour
+
+// salesPeriodRounding - точность, с которой определяется конец периода, если он не указан. Конец периода
+// округляется вверх, чтобы в него попадали все уже созданные заказы, а повторные запросы дашборда в течение
+// этого времени были одинаковыми и попадали в кэш показателей.
+const salesPeriodRounding = time.Minute
+
+type AnalyticsHandler struct {
+	analytics order.Analytics
+}
+
+func NewAnalyticsHandler(analytics order.Analytics) *AnalyticsHandler {
+	return &AnalyticsHandler{analytics: analytics}
+}
+
+// GetSales возвращает показатели продаж за период [from, to) с группировкой по признакам из параметра group_by,
+// перечисленным через запятую (см. order.Dimension). По умолчанию период - последние 30 дней.
+func (h *AnalyticsHandler) GetSales(r *http.Request) (any, error) {
+	from, err := parseTime(r, "from")
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseTime(r, "to")
+	if err != nil {
+		return nil, err
+	}
+
+	if to.IsZero() {
+		to = time.Now().Truncate(salesPeriodRounding).Add(salesPeriodRounding)
+	}
+
+	if from.IsZero() {
+		from = to.Add(-defaultSalesPeriod)
+	}
+
+	query := order.SalesQuery{From: from, To: to}
+	if groupBy := r.URL.Query().Get("group_by"); groupBy != "" {
+		for _, dimension := range strings.Split(groupBy, ",") {
+			query.GroupBy = append(query.GroupBy, order.Dimension(strings.TrimSpace(dimension)))
+		}
+	}
+
+	err = query.Validate()
+	if err != nil {
+		return nil, httperrors.NewHttpError(err.Error(), http.StatusBadRequest)
+	}
+
+	rows, err := h.analytics.Sales(r.Context(), query)
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
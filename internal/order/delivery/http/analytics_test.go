@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wb-l0/internal/order"
+)
+
+// recordingAnalytics запоминает запросы показателей продаж.
+type recordingAnalytics struct {
+	queries []order.SalesQuery
+}
+
+func (a *recordingAnalytics) Sales(_ context.Context, query order.SalesQuery) ([]*order.SalesRow, error) {
+	a.queries = append(a.queries, query)
+	return nil, nil
+}
+
+func TestGetSalesDefaultPeriod(t *testing.T) {
+	analytics := &recordingAnalytics{}
+	handler := NewAnalyticsHandler(analytics)
+
+	// Запросы повторяются, если между ними сменилась минута.
+	var before time.Time
+	for attempt := 0; attempt < 3; attempt++ {
+		analytics.queries = nil
+		before = time.Now()
+		for i := 0; i < 2; i++ {
+			_, err := handler.GetSales(httptest.NewRequest("GET", "/analytics/sales", nil))
+			if err != nil {
+				t.Fatalf("error getting sales: %s", err)
+			}
+		}
+
+		if time.Now().Truncate(salesPeriodRounding).Equal(before.Truncate(salesPeriodRounding)) {
+			break
+		}
+	}
+
+	first, second := analytics.queries[0], analytics.queries[1]
+	if !first.To.Equal(second.To) || !first.From.Equal(second.From) {
+		t.Fatalf("default periods differ: [%s, %s) and [%s, %s)", first.From, first.To, second.From, second.To)
+	}
+
+	if first.To.Before(before) {
+		t.Errorf("period end %s is before request time %s", first.To, before)
+	}
+
+	if got := first.To.Sub(first.From); got != defaultSalesPeriod {
+		t.Errorf("got period %s, want %s", got, defaultSalesPeriod)
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net/http"
+
+	"wb-l0/internal/order/consistency"
+)
+
+type ConsistencyHandler struct {
+	checker       *consistency.Checker
+	defaultSample int
+}
+
+// NewConsistencyHandler создаёт обработчики сверки кэшей с основным хранилищем. defaultSample - размер выборки,
+// если он не указан в запросе.
+func NewConsistencyHandler(checker *consistency.Checker, defaultSample int) *ConsistencyHandler {
+	return &ConsistencyHandler{checker: checker, defaultSample: defaultSample}
+}
+
+// Check сверяет кэши с основным хранилищем и возвращает отчёты о них. Параметр sample задаёт количество
+// проверяемых заказов в каждом кэше (0 - все заказы), repair=true включает исправление расхождений.
+func (h *ConsistencyHandler) Check(r *http.Request) (any, error) {
+	opts := consistency.Options{Sample: h.defaultSample}
+	if r.URL.Query().Has("sample") {
+		sample, err := parseInt(r, "sample")
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Sample = sample
+	}
+
+	repair, err := parseBool(r, "repair")
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Repair = repair
+	return h.checker.Check(r.Context(), opts)
+}
+
+// GetLastReports возвращает отчёты последней сверки, периодической или запрошенной вручную.
+func (h *ConsistencyHandler) GetLastReports(r *http.Request) (any, error) {
+	reports := h.checker.LastReports()
+	if reports == nil {
+		return []*consistency.Report{}, nil
+	}
+
+	return reports, nil
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/export"
+)
+
+type ExportHandler struct {
+	exporter order.Exporter
+}
+
+func NewExportHandler(exporter order.Exporter) *ExportHandler {
+	return &ExportHandler{exporter: exporter}
+}
+
+// ExportOrders выгружает заказы, отобранные по фильтру (см. parseFilter), в формате из параметра format: ndjson
+// (по умолчанию) или csv. Если gzip=true, выгрузка сжимается. Заказы передаются клиенту по мере чтения из базы
+// данных.
+func (h *ExportHandler) ExportOrders(w http.ResponseWriter, r *http.Request) error {
+	filter, err := parseFilter(r)
+	if err != nil {
+		return err
+	}
+
+	format := export.FormatNDJSON
+	if value := r.URL.Query().Get("format"); value != "" {
+		format, err = export.ParseFormat(value)
+		if err != nil {
+			return invalidParameter("format")
+		}
+	}
+
+	compress, err := parseBool(r, "gzip")
+	if err != nil {
+		return err
+	}
+
+	filename, contentType := "orders."+string(format), format.ContentType()
+	if compress {
+		filename, contentType = filename+".gz", "application/gzip"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	writer := export.NewWriter(w, format, compress)
+	err = h.exporter.ExportOrders(r.Context(), filter, writer.Write)
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
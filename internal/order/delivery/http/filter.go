@@ -0,0 +1,87 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/httperrors"
+)
+
+// parseFilter читает фильтр заказов из параметров запроса: customer_id, delivery_service, state, created_from и
+// created_to (в формате RFC 3339 или YYYY-MM-DD).
+func parseFilter(r *http.Request) (order.Filter, error) {
+	query := r.URL.Query()
+	filter := order.Filter{
+		CustomerID:      query.Get("customer_id"),
+		DeliveryService: query.Get("delivery_service"),
+		State:           order.State(query.Get("state")),
+	}
+
+	if filter.State != "" && !filter.State.Valid() {
+		return order.Filter{}, invalidParameter("state")
+	}
+
+	var err error
+	filter.CreatedFrom, err = parseTime(r, "created_from")
+	if err != nil {
+		return order.Filter{}, err
+	}
+
+	filter.CreatedTo, err = parseTime(r, "created_to")
+	if err != nil {
+		return order.Filter{}, err
+	}
+
+	return filter, nil
+}
+
+func parseTime(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, invalidParameter(name)
+}
+
+func parseInt(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, invalidParameter(name)
+	}
+
+	return parsed, nil
+}
+
+func parseBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidParameter(name)
+	}
+
+	return parsed, nil
+}
+
+func invalidParameter(name string) error {
+	return httperrors.NewHttpError(fmt.Sprintf("invalid value of parameter %s", name), http.StatusBadRequest)
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"encoding/json"
 	"net/http"
 	"wb-l0/pkg/httperrors"
 
@@ -28,5 +29,17 @@ func (h *OrderHandler) GetOrder(r *http.Request) (any, error) {
 		return nil, err
 	}
 
-	return o, nil
+	return withETag(o, o.Version), nil
+}
+
+// ValidateOrder проверяет заказ из тела запроса по тем же правилам, что и при получении из NATS, не сохраняя его.
+// Корректный заказ подтверждается ответом 204, при нарушениях возвращается 422 со списком нарушений.
+func (h *OrderHandler) ValidateOrder(r *http.Request) (any, error) {
+	var o order.Order
+	err := json.NewDecoder(r.Body).Decode(&o)
+	if err != nil {
+		return nil, httperrors.NewHttpError("request body must contain an order", http.StatusBadRequest)
+	}
+
+	return nil, o.Validate()
 }
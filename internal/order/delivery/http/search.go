@@ -0,0 +1,114 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"wb-l0/internal/order"
+)
+
+type SearchHandler struct {
+	searcher order.Searcher
+}
+
+func NewSearchHandler(searcher order.Searcher) *SearchHandler {
+	return &SearchHandler{searcher: searcher}
+}
+
+// OrderPage - страница списка заказов. NextCursor передаётся в параметре cursor для получения следующей страницы
+// и пуст на последней странице.
+type OrderPage struct {
+	Orders     []*order.Summary `json:"orders"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// SearchOrders ищет заказы по тексту из параметра q и фильтру (см. parseFilter), не более limit результатов.
+// Возвращает краткие сведения о найденных заказах.
+func (h *SearchHandler) SearchOrders(r *http.Request) (any, error) {
+	_, summaries, err := h.query(r, r.URL.Query().Get("q"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// ListOrders возвращает страницу кратких сведений о заказах, отобранных по фильтру, от новых к старым. Следующая
+// страница запрашивается с параметром cursor из ответа.
+func (h *SearchHandler) ListOrders(r *http.Request) (any, error) {
+	after, err := parseCursor(r, "cursor")
+	if err != nil {
+		return nil, err
+	}
+
+	query, summaries, err := h.query(r, "", after)
+	if err != nil {
+		return nil, err
+	}
+
+	page := OrderPage{Orders: summaries}
+	if len(summaries) > 0 && len(summaries) == query.PageSize() {
+		page.NextCursor = encodeCursor(order.CursorAfter(summaries[len(summaries)-1]))
+	}
+
+	return page, nil
+}
+
+func (h *SearchHandler) query(
+	r *http.Request, text string, after *order.Cursor,
+) (order.SearchQuery, []*order.Summary, error) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		return order.SearchQuery{}, nil, err
+	}
+
+	limit, err := parseInt(r, "limit")
+	if err != nil {
+		return order.SearchQuery{}, nil, err
+	}
+
+	query := order.SearchQuery{
+		Text:   text,
+		Filter: filter,
+		Limit:  limit,
+		After:  after,
+	}
+	summaries, err := h.searcher.SearchOrders(r.Context(), query)
+	if err != nil {
+		return order.SearchQuery{}, nil, err
+	}
+
+	// Пустой результат отдаётся массивом, а не null.
+	if summaries == nil {
+		summaries = []*order.Summary{}
+	}
+
+	return query, summaries, nil
+}
+
+// parseCursor читает позицию в списке заказов, закодированную encodeCursor.
+func parseCursor(r *http.Request, name string) (*order.Cursor, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalidParameter(name)
+	}
+
+	var cursor order.Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.OrderUID == "" || cursor.DateCreated.IsZero() {
+		return nil, invalidParameter(name)
+	}
+
+	return &cursor, nil
+}
+
+// encodeCursor кодирует позицию в списке заказов для передачи в параметре запроса.
+func encodeCursor(cursor *order.Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/httperrors"
+)
+
+// pagingSearcher выдаёт заказы summaries постранично, как хранилище без текста запроса.
+type pagingSearcher struct {
+	summaries []*order.Summary
+	queries   []order.SearchQuery
+}
+
+func (s *pagingSearcher) SearchOrders(_ context.Context, query order.SearchQuery) ([]*order.Summary, error) {
+	s.queries = append(s.queries, query)
+
+	var found []*order.Summary
+	for _, summary := range s.summaries {
+		if query.After != nil && !query.After.Before(summary) {
+			continue
+		}
+
+		found = append(found, summary)
+		if len(found) == query.PageSize() {
+			break
+		}
+	}
+
+	return found, nil
+}
+
+func TestListOrdersPages(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// Заказы упорядочены от новых к старым, у двух заказов одинаковая дата создания.
+	searcher := &pagingSearcher{summaries: []*order.Summary{
+		{OrderUID: "d", DateCreated: created.Add(time.Hour)},
+		{OrderUID: "c", DateCreated: created},
+		{OrderUID: "b", DateCreated: created},
+		{OrderUID: "a", DateCreated: created.Add(-time.Hour)},
+	}}
+	handler := NewSearchHandler(searcher)
+
+	var got []string
+	cursor := ""
+	for pages := 0; pages < 5; pages++ {
+		target := "/orders?limit=2&customer_id=test"
+		if cursor != "" {
+			target += "&cursor=" + cursor
+		}
+
+		response, err := handler.ListOrders(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatalf("error listing orders: %s", err)
+		}
+
+		page := response.(OrderPage)
+		for _, summary := range page.Orders {
+			got = append(got, summary.OrderUID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	want := []string{"d", "c", "b", "a"}
+	if len(got) != len(want) {
+		t.Fatalf("got orders %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got orders %v, want %v", got, want)
+		}
+	}
+
+	// Две полные страницы и пустая последняя.
+	if len(searcher.queries) != 3 {
+		t.Fatalf("got %d queries, want 3", len(searcher.queries))
+	}
+	for _, query := range searcher.queries {
+		if query.Filter.CustomerID != "test" {
+			t.Errorf("got customer filter %q, want test", query.Filter.CustomerID)
+		}
+	}
+}
+
+func TestListOrdersLastPage(t *testing.T) {
+	searcher := &pagingSearcher{summaries: []*order.Summary{{OrderUID: "a", DateCreated: time.Now()}}}
+
+	response, err := NewSearchHandler(searcher).ListOrders(httptest.NewRequest("GET", "/orders", nil))
+	if err != nil {
+		t.Fatalf("error listing orders: %s", err)
+	}
+
+	if page := response.(OrderPage); page.NextCursor != "" || len(page.Orders) != 1 {
+		t.Fatalf("got %d orders and cursor %q, want 1 order without cursor", len(page.Orders), page.NextCursor)
+	}
+}
+
+func TestListOrdersInvalidCursor(t *testing.T) {
+	handler := NewSearchHandler(&pagingSearcher{})
+
+	for _, cursor := range []string{"!", "bm90IGpzb24", encodeCursor(&order.Cursor{OrderUID: "a"})} {
+		_, err := handler.ListOrders(httptest.NewRequest("GET", "/orders?cursor="+cursor, nil))
+
+		var httpErr httperrors.Error
+		if !errors.As(err, &httpErr) || httpErr.GetStatusCode() != http.StatusBadRequest {
+			t.Errorf("cursor %q: got error %v, want bad request", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/httperrors"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type StateHandler struct {
+	states      order.StateRepository
+	invalidator order.Invalidator
+}
+
+// NewStateHandler создаёт обработчики смены состояний заказов. После смены состояния заказ сбрасывается
+// из кэша через invalidator.
+func NewStateHandler(states order.StateRepository, invalidator order.Invalidator) *StateHandler {
+	return &StateHandler{states: states, invalidator: invalidator}
+}
+
+// GetTransitions возвращает историю смены состояний заказа id.
+func (h *StateHandler) GetTransitions(r *http.Request) (any, error) {
+	transitions, err := h.states.GetStateTransitions(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, order.ErrNotFound) {
+			return nil, httperrors.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+type changeStateRequest struct {
+	State   order.State `json:"state"`
+	Version *int64      `json:"version"`
+}
+
+// ChangeState переводит заказ id в состояние из тела запроса: {"state": "paid"}. Возвращает выполненный переход
+// и новую версию заказа в заголовке ETag. Недопустимый переход отклоняется с кодом 409.
+//
+// Ожидаемую версию заказа можно передать в заголовке If-Match или в поле version тела запроса
+// (см. expectedVersion); если заказ уже изменён, запрос отклоняется с кодом 412 или 409 соответственно.
+func (h *StateHandler) ChangeState(r *http.Request) (any, error) {
+	var request changeStateRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.State == "" {
+		return nil, httperrors.NewHttpError("request body must contain order state", http.StatusBadRequest)
+	}
+
+	version, precondition, err := expectedVersion(r, request.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	uid := chi.URLParam(r, "id")
+	transition, version, err := h.states.ChangeOrderState(r.Context(), order.StateChange{
+		OrderUID: uid,
+		State:    request.State,
+		Version:  version,
+	})
+	if err != nil {
+		var unknownState *order.UnknownStateError
+		var transitionError *order.TransitionError
+		var conflict *order.VersionConflictError
+		switch {
+		case errors.Is(err, order.ErrNotFound):
+			return nil, httperrors.ErrNotFound
+		case errors.As(err, &unknownState):
+			return nil, httperrors.NewHttpError(err.Error(), http.StatusBadRequest)
+		case errors.As(err, &transitionError):
+			return nil, httperrors.NewHttpError(err.Error(), http.StatusConflict)
+		case errors.As(err, &conflict):
+			return nil, versionConflictError(conflict, precondition)
+		default:
+			return nil, err
+		}
+	}
+
+	err = h.invalidator.InvalidateOrder(r.Context(), uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return withETag(transition, version), nil
+}
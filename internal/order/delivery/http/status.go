@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/httperrors"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ItemStatusHandler struct {
+	statuses    order.ItemStatusRepository
+	invalidator order.Invalidator
+}
+
+// NewItemStatusHandler создаёт обработчики истории статусов товаров. После изменения статуса заказ сбрасывается
+// из кэша через invalidator.
+func NewItemStatusHandler(statuses order.ItemStatusRepository, invalidator order.Invalidator) *ItemStatusHandler {
+	return &ItemStatusHandler{statuses: statuses, invalidator: invalidator}
+}
+
+// GetHistory возвращает историю статусов товара chrt_id в заказе id.
+func (h *ItemStatusHandler) GetHistory(r *http.Request) (any, error) {
+	chrtID, err := strconv.ParseInt(chi.URLParam(r, "chrt_id"), 10, 64)
+	if err != nil {
+		return nil, httperrors.ErrNotFound
+	}
+
+	history, err := h.statuses.GetItemStatusHistory(r.Context(), chi.URLParam(r, "id"), chrtID)
+	if err != nil {
+		if errors.Is(err, order.ErrNotFound) {
+			return nil, httperrors.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return history, nil
+}
+
+type applyStatusRequest struct {
+	Status  *int   `json:"status"`
+	Version *int64 `json:"version"`
+}
+
+// ApplyStatus меняет статус товара chrt_id в заказе id на указанный в теле запроса: {"status": 203}.
+// Новая версия заказа возвращается в заголовке ETag, ожидаемая версия проверяется так же, как в
+// StateHandler.ChangeState.
+func (h *ItemStatusHandler) ApplyStatus(r *http.Request) (any, error) {
+	chrtID, err := strconv.ParseInt(chi.URLParam(r, "chrt_id"), 10, 64)
+	if err != nil {
+		return nil, httperrors.ErrNotFound
+	}
+
+	var request applyStatusRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Status == nil {
+		return nil, httperrors.NewHttpError("request body must contain item status", http.StatusBadRequest)
+	}
+
+	version, precondition, err := expectedVersion(r, request.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	uid := chi.URLParam(r, "id")
+	version, err = h.statuses.ApplyItemStatus(r.Context(), order.ItemStatusChange{
+		OrderUID: uid,
+		ChrtID:   chrtID,
+		Status:   *request.Status,
+		Version:  version,
+	})
+	if err != nil {
+		var conflict *order.VersionConflictError
+		switch {
+		case errors.Is(err, order.ErrNotFound):
+			return nil, httperrors.ErrNotFound
+		case errors.As(err, &conflict):
+			return nil, versionConflictError(conflict, precondition)
+		default:
+			return nil, err
+		}
+	}
+
+	err = h.invalidator.InvalidateOrder(r.Context(), uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return withETag(nil, version), nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/httperrors"
+	"wb-l0/pkg/httpresponse"
+)
+
+// withETag добавляет к ответу заголовок ETag с версией заказа. Версия неизвестна у заказов, закэшированных
+// до её появления, таким ответам ETag не добавляется.
+func withETag(data any, version int64) *httpresponse.Response {
+	response := httpresponse.New(data)
+	if version > 0 {
+		response.WithHeader("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+	}
+
+	return response
+}
+
+// expectedVersion возвращает версию заказа, которую ожидает автор изменения: из заголовка If-Match или, если его
+// нет, из поля version тела запроса. precondition равен true, если версия передана в заголовке.
+//
+// If-Match сравнивается со строгим ETag заказа: слабые ETag и ETag, не содержащие версию, ни с чем не совпадают,
+// поэтому условие, в котором нет других ETag, не выполняется и запрос отклоняется с кодом 412. Список из ETag разных
+// версий тоже отклоняется с кодом 412: изменение выполняется только при единственной ожидаемой версии.
+func expectedVersion(r *http.Request, body *int64) (version int64, precondition bool, err error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if body == nil {
+			return order.AnyVersion, false, nil
+		}
+
+		if *body <= 0 {
+			return 0, false, httperrors.NewHttpError("order version must be positive", http.StatusBadRequest)
+		}
+
+		return *body, false, nil
+	}
+
+	if strings.TrimSpace(header) == "*" {
+		return order.AnyVersion, true, nil
+	}
+
+	tags, ok := parseETags(header)
+	if !ok {
+		return 0, true, httperrors.NewHttpError("If-Match must contain a list of ETags", http.StatusBadRequest)
+	}
+
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		parsed, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+		if err != nil || parsed <= 0 {
+			continue
+		}
+
+		if version != 0 && version != parsed {
+			return 0, true, httperrors.NewHttpError(
+				"If-Match must contain a single order version", http.StatusPreconditionFailed,
+			)
+		}
+		version = parsed
+	}
+
+	if version == 0 {
+		return 0, true, httperrors.NewHttpError(
+			"If-Match does not contain a strong order ETag", http.StatusPreconditionFailed,
+		)
+	}
+
+	return version, true, nil
+}
+
+// parseETags разбирает список ETag через запятую. Каждый ETag возвращается вместе с кавычками и префиксом W/.
+func parseETags(header string) ([]string, bool) {
+	var tags []string
+	for rest := header; ; {
+		rest = strings.TrimLeft(rest, " \t")
+		start := rest
+		rest = strings.TrimPrefix(rest, "W/")
+		if !strings.HasPrefix(rest, `"`) {
+			return nil, false
+		}
+
+		end := strings.IndexByte(rest[1:], '"')
+		if end < 0 {
+			return nil, false
+		}
+		rest = rest[end+2:]
+		tags = append(tags, start[:len(start)-len(rest)])
+
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == "" {
+			return tags, true
+		}
+
+		if rest[0] != ',' {
+			return nil, false
+		}
+		rest = rest[1:]
+	}
+}
+
+// versionConflictError возвращает ответ на попытку изменить заказ другой версии: 412, если ожидаемая версия
+// передана в заголовке If-Match, и 409, если в теле запроса.
+func versionConflictError(conflict *order.VersionConflictError, precondition bool) error {
+	status := http.StatusConflict
+	if precondition {
+		status = http.StatusPreconditionFailed
+	}
+
+	return httperrors.NewHttpError(fmt.Sprintf("order was modified: %s", conflict), status)
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/httperrors"
+	"wb-l0/pkg/httpresponse"
+)
+
+func int64Pointer(value int64) *int64 {
+	return &value
+}
+
+// statusCode возвращает HTTP-код ошибки err или 0, если это не ошибка HTTP.
+func statusCode(err error) int {
+	var httpErr httperrors.Error
+	if !errors.As(err, &httpErr) {
+		return 0
+	}
+
+	return httpErr.GetStatusCode()
+}
+
+func TestExpectedVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		body         *int64
+		version      int64
+		precondition bool
+		status       int
+	}{
+		{name: "no version", version: order.AnyVersion},
+		{name: "body", body: int64Pointer(3), version: 3},
+		{name: "zero body", body: int64Pointer(0), status: http.StatusBadRequest},
+		{name: "negative body", body: int64Pointer(-1), status: http.StatusBadRequest},
+		{name: "header", header: `"3"`, version: 3, precondition: true},
+		{name: "header over body", header: `"3"`, body: int64Pointer(2), version: 3, precondition: true},
+		{name: "any", header: "*", version: order.AnyVersion, precondition: true},
+		{name: "any over body", header: "*", body: int64Pointer(2), version: order.AnyVersion, precondition: true},
+		{name: "list of one version", header: ` "3" , W/"3"`, version: 3, precondition: true},
+		{name: "repeated version", header: `"3", "3"`, version: 3, precondition: true},
+		{name: "strong and other tag", header: `"abc", "3"`, version: 3, precondition: true},
+		{name: "weak", header: `W/"3"`, precondition: true, status: http.StatusPreconditionFailed},
+		{name: "several versions", header: `"2", "3"`, precondition: true, status: http.StatusPreconditionFailed},
+		{name: "other tag", header: `"abc"`, precondition: true, status: http.StatusPreconditionFailed},
+		{name: "zero version", header: `"0"`, precondition: true, status: http.StatusPreconditionFailed},
+		{name: "unquoted", header: "3", precondition: true, status: http.StatusBadRequest},
+		{name: "unterminated", header: `"3`, precondition: true, status: http.StatusBadRequest},
+		{name: "empty list item", header: `"3",`, precondition: true, status: http.StatusBadRequest},
+		{name: "garbage after tag", header: `"3"x`, precondition: true, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/orders/test/state", nil)
+			if test.header != "" {
+				r.Header.Set("If-Match", test.header)
+			}
+
+			version, precondition, err := expectedVersion(r, test.body)
+			if test.status != 0 {
+				if statusCode(err) != test.status {
+					t.Fatalf("got error %v, want status %d", err, test.status)
+				}
+			} else if err != nil {
+				t.Fatalf("got error %s", err)
+			}
+
+			if version != test.version || precondition != test.precondition {
+				t.Errorf("got version %d and precondition %t, want %d and %t",
+					version, precondition, test.version, test.precondition)
+			}
+		})
+	}
+}
+
+// conflictingStates отклоняет любую смену состояния из-за конфликта версий.
+type conflictingStates struct {
+	changes []order.StateChange
+}
+
+func (s *conflictingStates) ChangeOrderState(
+	_ context.Context, change order.StateChange,
+) (*order.StateTransition, int64, error) {
+	s.changes = append(s.changes, change)
+	if change.Version == order.AnyVersion || change.Version == 5 {
+		return &order.StateTransition{To: change.State, ChangedAt: time.Now()}, 6, nil
+	}
+
+	return nil, 0, &order.VersionConflictError{Expected: change.Version, Actual: 5}
+}
+
+func (*conflictingStates) GetStateTransitions(context.Context, string) ([]*order.StateTransition, error) {
+	return nil, nil
+}
+
+type noopInvalidator struct{}
+
+func (noopInvalidator) InvalidateOrder(context.Context, string) error { return nil }
+func (noopInvalidator) InvalidateAll(context.Context) error           { return nil }
+
+func TestChangeStateVersionConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		status int
+	}{
+		{name: "header conflict", header: `"4"`, body: `{"state": "paid"}`, status: http.StatusPreconditionFailed},
+		{name: "body conflict", body: `{"state": "paid", "version": 4}`, status: http.StatusConflict},
+		{name: "header over body", header: `"4"`, body: `{"state": "paid", "version": 5}`,
+			status: http.StatusPreconditionFailed},
+		{name: "weak header", header: `W/"5"`, body: `{"state": "paid"}`, status: http.StatusPreconditionFailed},
+		{name: "header match", header: `"5"`, body: `{"state": "paid", "version": 4}`},
+		{name: "body match", body: `{"state": "paid", "version": 5}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			states := &conflictingStates{}
+			handler := NewStateHandler(states, noopInvalidator{})
+
+			r := httptest.NewRequest("POST", "/orders/test/state", strings.NewReader(test.body))
+			if test.header != "" {
+				r.Header.Set("If-Match", test.header)
+			}
+
+			response, err := handler.ChangeState(r)
+			if test.status != 0 {
+				if statusCode(err) != test.status {
+					t.Fatalf("got error %v, want status %d", err, test.status)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got error %s", err)
+			}
+
+			if etag := response.(*httpresponse.Response).Header.Get("ETag"); etag != `"6"` {
+				t.Errorf("got ETag %s, want \"6\"", etag)
+			}
+		})
+	}
+}
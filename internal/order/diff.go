@@ -0,0 +1,94 @@
+package order
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// FieldDifference - различие значений поля двух заказов.
+type FieldDifference struct {
+	// Path - путь к полю в JSON, например, items[2].price. Для массивов также сравнивается длина (items.length).
+	Path string
+	Want any
+	Got  any
+}
+
+// Diff сравнивает заказы в том виде, в котором они передаются клиентам (в JSON), и возвращает различающиеся
+// поля в порядке их путей. Время сравнивается с точностью до микросекунды, с которой его хранит Postgres.
+func Diff(want, got *Order) ([]FieldDifference, error) {
+	wantFields, err := flattenOrder(want)
+	if err != nil {
+		return nil, err
+	}
+
+	gotFields, err := flattenOrder(got)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for path := range wantFields {
+		paths = append(paths, path)
+	}
+	for path := range gotFields {
+		if _, ok := wantFields[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var differences []FieldDifference
+	for _, path := range paths {
+		wantValue, gotValue := wantFields[path], gotFields[path]
+		if !reflect.DeepEqual(wantValue, gotValue) {
+			differences = append(differences, FieldDifference{Path: path, Want: wantValue, Got: gotValue})
+		}
+	}
+
+	return differences, nil
+}
+
+// flattenOrder переводит заказ в JSON и собирает значения всех его полей по путям.
+func flattenOrder(o *Order) (map[string]any, error) {
+	normalized := *o
+	normalized.DateCreated = o.DateCreated.UTC().Truncate(time.Microsecond)
+	normalized.StateChangedAt = o.StateChangedAt.UTC().Truncate(time.Microsecond)
+
+	data, err := json.Marshal(&normalized)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling order: %s", err)
+	}
+
+	var value any
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling order: %s", err)
+	}
+
+	fields := make(map[string]any)
+	flattenValue(fields, "", value)
+	return fields, nil
+}
+
+func flattenValue(fields map[string]any, path string, value any) {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, nested := range value {
+			if path == "" {
+				flattenValue(fields, key, nested)
+			} else {
+				flattenValue(fields, path+"."+key, nested)
+			}
+		}
+	case []any:
+		fields[path+".length"] = len(value)
+		for i, nested := range value {
+			flattenValue(fields, fmt.Sprintf("%s[%d]", path, i), nested)
+		}
+	default:
+		fields[path] = value
+	}
+}
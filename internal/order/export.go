@@ -0,0 +1,10 @@
+package order
+
+import "context"
+
+// Exporter - хранилище, из которого можно выгрузить заказы потоком, не загружая их в память целиком.
+type Exporter interface {
+	// ExportOrders передаёт fn отобранные по фильтру заказы по одному в порядке их создания. Выгрузка
+	// прекращается при первой ошибке fn, которая возвращается вызывающей стороне.
+	ExportOrders(ctx context.Context, filter Filter, fn func(o *Order) error) error
+}
@@ -0,0 +1,209 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"wb-l0/internal/order"
+)
+
+// Format - формат выгрузки заказов.
+type Format string
+
+const (
+	// FormatNDJSON - по одному заказу в формате JSON на строку.
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV - по одной строке на товар, сведения о заказе повторяются в каждой строке. Заказ без товаров
+	// выгружается одной строкой с пустыми полями товара.
+	FormatCSV Format = "csv"
+)
+
+// ParseFormat проверяет название формата выгрузки.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case FormatNDJSON, FormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown export format \"%s\"", s)
+	}
+}
+
+// ContentType возвращает MIME-тип выгрузки в формате f.
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+
+	return "application/x-ndjson"
+}
+
+// Writer записывает выгружаемые заказы в поток в заданном формате, при необходимости сжимая его gzip.
+type Writer struct {
+	buffer  *bufio.Writer
+	gzip    *gzip.Writer
+	encoder encoder
+}
+
+// encoder записывает заказы в одном из форматов выгрузки.
+type encoder interface {
+	Write(o *order.Order) error
+	Flush() error
+}
+
+// NewWriter создаёт Writer, записывающий заказы в w. Записанные данные буферизуются, поэтому после записи
+// последнего заказа нужно вызвать Close.
+func NewWriter(w io.Writer, format Format, compress bool) *Writer {
+	writer := &Writer{}
+	if compress {
+		writer.gzip = gzip.NewWriter(w)
+		w = writer.gzip
+	}
+
+	writer.buffer = bufio.NewWriter(w)
+	if format == FormatCSV {
+		writer.encoder = &csvEncoder{writer: csv.NewWriter(writer.buffer)}
+	} else {
+		writer.encoder = jsonEncoder{encoder: json.NewEncoder(writer.buffer)}
+	}
+
+	return writer
+}
+
+// Write записывает заказ.
+func (w *Writer) Write(o *order.Order) error {
+	return w.encoder.Write(o)
+}
+
+// Close записывает буферизованные данные и завершает сжатый поток. Нижележащий поток не закрывается.
+func (w *Writer) Close() error {
+	err := w.encoder.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = w.buffer.Flush()
+	if err != nil {
+		return err
+	}
+
+	if w.gzip != nil {
+		return w.gzip.Close()
+	}
+
+	return nil
+}
+
+type jsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e jsonEncoder) Write(o *order.Order) error {
+	return e.encoder.Encode(o)
+}
+
+func (jsonEncoder) Flush() error {
+	return nil
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder) Write(o *order.Order) error {
+	err := e.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	return e.writer.WriteAll(csvRecords(o))
+}
+
+// Flush дописывает буферизованные строки. Пустая выгрузка всё равно содержит заголовок.
+func (e *csvEncoder) Flush() error {
+	err := e.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+
+	e.header = true
+	return e.writer.Write(csvHeader)
+}
+
+// csvHeader - заголовок выгрузки в формате CSV, значения полей формируются в csvRecords в том же порядке.
+var csvHeader = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service",
+	"shardkey", "sm_id", "date_created", "oof_shard", "state", "state_changed_at", "version",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address", "delivery_region",
+	"delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider", "payment_amount",
+	"payment_dt", "payment_bank", "payment_delivery_cost", "payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale", "item_size",
+	"item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+func csvRecords(o *order.Order) [][]string {
+	fields := []string{
+		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID, o.DeliveryService,
+		o.ShardKey, strconv.FormatInt(o.SmID, 10), formatTime(o.DateCreated), o.OofShard, string(o.State),
+		formatTime(o.StateChangedAt), strconv.FormatInt(o.Version, 10),
+	}
+
+	delivery := o.Delivery
+	if delivery == nil {
+		delivery = &order.Delivery{}
+	}
+	fields = append(
+		fields,
+		delivery.Name, delivery.Phone, delivery.Zip, delivery.City, delivery.Address, delivery.Region, delivery.Email,
+	)
+
+	if p := o.Payment; p != nil {
+		fields = append(
+			fields,
+			p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount.String(), strconv.FormatInt(p.PaymentDt, 10),
+			p.Bank, p.DeliveryCost.String(), p.GoodsTotal.String(), p.CustomFee.String(),
+		)
+	} else {
+		fields = append(fields, make([]string, 10)...)
+	}
+
+	if len(o.Items) == 0 {
+		return [][]string{append(fields, make([]string, 11)...)}
+	}
+
+	records := make([][]string, 0, len(o.Items))
+	for _, item := range o.Items {
+		record := append(fields[:len(fields):len(fields)],
+			strconv.FormatInt(item.ChrtID, 10), item.TrackNumber, item.Price.String(), item.RID, item.Name,
+			strconv.FormatFloat(item.Sale, 'f', -1, 64), item.Size, item.TotalPrice.String(),
+			strconv.FormatInt(item.NmID, 10), item.Brand, strconv.Itoa(item.Status),
+		)
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,42 @@
+package order
+
+import "strings"
+
+// languages - коды языков по ISO 639-1, допустимые в поле locale.
+var languages = map[string]struct{}{}
+
+func init() {
+	codes := "aa ab ae af ak am an ar as av ay az ba be bg bi bm bn bo br bs ca ce ch co cr cs cu cv cy " +
+		"da de dv dz ee el en eo es et eu fa ff fi fj fo fr fy ga gd gl gn gu gv ha he hi ho hr ht hu hy hz " +
+		"ia id ie ig ii ik io is it iu ja jv ka kg ki kj kk kl km kn ko kr ks ku kv kw ky la lb lg li ln lo " +
+		"lt lu lv mg mh mi mk ml mn mr ms mt my na nb nd ne ng nl nn no nr nv ny oc oj om or os pa pi pl ps " +
+		"pt qu rm rn ro ru rw sa sc sd se sg si sk sl sm sn so sq sr ss st su sv sw ta te tg th ti tk tl tn " +
+		"to tr ts tt tw ty ug uk ur uz ve vi vo wa wo xh yi yo za zh zu"
+
+	for _, code := range strings.Fields(codes) {
+		languages[code] = struct{}{}
+	}
+}
+
+// KnownLocale проверяет, что locale - известный код языка по ISO 639-1 (например, ru), возможно, с кодом
+// региона из двух заглавных букв через дефис или подчёркивание (ru-RU, en_US).
+func KnownLocale(locale string) bool {
+	language, region, hasRegion := strings.Cut(locale, "-")
+	if !hasRegion {
+		language, region, hasRegion = strings.Cut(locale, "_")
+	}
+
+	if _, ok := languages[language]; !ok {
+		return false
+	}
+
+	if !hasRegion {
+		return true
+	}
+
+	return len(region) == 2 && isUpper(region[0]) && isUpper(region[1])
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
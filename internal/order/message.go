@@ -0,0 +1,37 @@
+package order
+
+import (
+	"context"
+	"time"
+)
+
+// RawMessage - исходное сообщение, из которого был получен заказ, в том виде, в котором оно пришло из NATS.
+type RawMessage struct {
+	OrderUID   string              `json:"order_uid" db:"order_uid"`
+	Subject    string              `json:"subject" db:"subject"`
+	Headers    map[string][]string `json:"headers" db:"headers"`
+	Body       []byte              `json:"body" db:"body"`
+	ReceivedAt time.Time           `json:"received_at" db:"received_at"`
+}
+
+// MessageCreator - хранилище, которое сохраняет заказ вместе с исходным сообщением.
+type MessageCreator interface {
+	// CreateOrderWithMessage сохраняет заказ так же, как Repository.CreateOrder, а если message не nil,
+	// то и исходное сообщение вместе с ним.
+	CreateOrderWithMessage(ctx context.Context, order *Order, message *RawMessage) error
+}
+
+// CreateOrderWithMessage сохраняет заказ вместе с исходным сообщением, если repository это поддерживает
+// (см. MessageCreator). Иначе сохраняется только заказ: например, кэши исходные сообщения не хранят.
+func CreateOrderWithMessage(ctx context.Context, repository Repository, order *Order, message *RawMessage) error {
+	if creator, ok := repository.(MessageCreator); ok {
+		return creator.CreateOrderWithMessage(ctx, order, message)
+	}
+
+	return repository.CreateOrder(ctx, order)
+}
+
+// RawMessageRepository - хранилище исходных сообщений.
+type RawMessageRepository interface {
+	GetRawMessage(ctx context.Context, uid string) (*RawMessage, error)
+}
@@ -1,9 +1,9 @@
 package order
 
 import (
-	"errors"
-	"fmt"
 	"time"
+
+	"wb-l0/pkg/money"
 )
 
 type Order struct {
@@ -21,40 +21,84 @@ type Order struct {
 	SmID              int64     `json:"sm_id" db:"sm_id"`
 	DateCreated       time.Time `json:"date_created" db:"date_created"`
 	OofShard          string    `json:"oof_shard" db:"oof_shard"`
+
+	// State - состояние заказа (см. State), StateChangedAt - время перехода в него.
+	State          State     `json:"state" db:"state"`
+	StateChangedAt time.Time `json:"state_changed_at" db:"state_changed_at"`
+
+	// Version - версия заказа, увеличивающаяся при каждом его изменении. Первая версия равна 1.
+	Version int64 `json:"version" db:"version"`
+}
+
+// InitState устанавливает состояние только что полученного заказа, если оно не было передано отправителем.
+func (o *Order) InitState(at time.Time) {
+	if o.State == "" {
+		o.State = StateCreated
+	}
+
+	if o.StateChangedAt.IsZero() {
+		o.StateChangedAt = at
+	}
 }
 
+// Validate проверяет заказ и возвращает *ValidationError со всеми найденными нарушениями или nil.
 func (o *Order) Validate() error {
-	if o.OrderUID == "" {
-		return errors.New("order uid is empty")
+	var v validator
+	o.validate(&v, time.Now())
+	return v.err()
+}
+
+func (o *Order) validate(v *validator, now time.Time) {
+	v.notEmpty("order_uid", o.OrderUID)
+	v.notEmpty("track_number", o.TrackNumber)
+
+	if !KnownLocale(o.Locale) {
+		v.add("locale", "unknown locale %q", o.Locale)
 	}
 
-	if o.Delivery == nil {
-		return errors.New("delivery info is empty")
+	if o.DateCreated.IsZero() {
+		v.add("date_created", "must be set")
+	} else if o.DateCreated.After(now.Add(MaxClockSkew)) {
+		v.add("date_created", "must not be in the future")
 	}
 
-	if o.Payment == nil {
-		return errors.New("payment info is empty")
+	if o.State != "" && !o.State.Valid() {
+		v.add("state", "unknown order state %q", o.State)
 	}
 
-	err := o.Payment.Validate()
-	if err != nil {
-		return fmt.Errorf("payment is invalid: %s", err)
+	if o.Delivery == nil {
+		v.add("delivery", "must not be null")
+	} else {
+		o.Delivery.validate(v, "delivery")
+	}
+
+	currency := ""
+	if o.Payment == nil {
+		v.add("payment", "must not be null")
+	} else {
+		o.Payment.validate(v, "payment")
+		currency = o.Payment.Currency
 	}
 
 	if o.Items == nil {
-		return errors.New("item list is nil")
+		v.add("items", "must not be null")
 	}
 
 	for i, item := range o.Items {
-		err := item.Validate()
-		if err != nil {
-			return fmt.Errorf("item %d is invalid: %s", i, err)
+		path := indexPath("items", i)
+		if item == nil {
+			v.add(path, "must not be null")
+			continue
 		}
-	}
 
-	return nil
+		item.validate(v, path, currency)
+	}
 }
 
+// MaxClockSkew - допустимое расхождение часов отправителя и сервиса: на столько date_created может опережать
+// текущее время.
+const MaxClockSkew = time.Minute
+
 type Delivery struct {
 	ID      int64  `json:"-" db:"id"`
 	Name    string `json:"name" db:"name"`
@@ -66,46 +110,113 @@ type Delivery struct {
 	Email   string `json:"email" db:"email"`
 }
 
+func (d *Delivery) Validate() error {
+	var v validator
+	d.validate(&v, "")
+	return v.err()
+}
+
+func (d *Delivery) validate(v *validator, prefix string) {
+	if !validPhone(d.Phone) {
+		v.add(fieldPath(prefix, "phone"), "must consist of %d digits or \"+\" and %d digits",
+			PhoneLength, PhoneLength-1)
+	}
+
+	if !validEmail(d.Email) {
+		v.add(fieldPath(prefix, "email"), "invalid email address %q", d.Email)
+	}
+}
+
 type Payment struct {
-	Transaction  string  `json:"transaction" db:"transaction"`
-	RequestID    string  `json:"request_id" db:"request_id"`
-	Currency     string  `json:"currency" db:"currency"`
-	Provider     string  `json:"provider" db:"provider"`
-	Amount       float64 `json:"amount" db:"amount"`
-	PaymentDt    int64   `json:"payment_dt" db:"payment_dt"`
-	Bank         string  `json:"bank" db:"bank"`
-	DeliveryCost float64 `json:"delivery_cost" db:"delivery_cost"`
-	GoodsTotal   float64 `json:"goods_total" db:"goods_total"`
-	CustomFee    float64 `json:"custom_fee" db:"custom_fee"`
+	Transaction  string       `json:"transaction" db:"transaction"`
+	RequestID    string       `json:"request_id" db:"request_id"`
+	Currency     string       `json:"currency" db:"currency"`
+	Provider     string       `json:"provider" db:"provider"`
+	Amount       money.Amount `json:"amount" db:"amount"`
+	PaymentDt    int64        `json:"payment_dt" db:"payment_dt"`
+	Bank         string       `json:"bank" db:"bank"`
+	DeliveryCost money.Amount `json:"delivery_cost" db:"delivery_cost"`
+	GoodsTotal   money.Amount `json:"goods_total" db:"goods_total"`
+	CustomFee    money.Amount `json:"custom_fee" db:"custom_fee"`
 }
 
 func (p *Payment) Validate() error {
-	if p.Transaction == "" {
-		return errors.New("transaction is empty")
+	var v validator
+	p.validate(&v, "")
+	return v.err()
+}
+
+func (p *Payment) validate(v *validator, prefix string) {
+	v.notEmpty(fieldPath(prefix, "transaction"), p.Transaction)
+
+	knownCurrency := money.KnownCurrency(p.Currency)
+	if !knownCurrency {
+		v.add(fieldPath(prefix, "currency"), "unknown ISO 4217 currency code %q", p.Currency)
+	}
+
+	amounts := []struct {
+		name   string
+		amount money.Amount
+	}{
+		{"amount", p.Amount},
+		{"delivery_cost", p.DeliveryCost},
+		{"goods_total", p.GoodsTotal},
+		{"custom_fee", p.CustomFee},
+	}
+
+	for _, a := range amounts {
+		// Точность суммы можно проверить только для известной валюты.
+		validateAmount(v, fieldPath(prefix, a.name), a.amount, p.Currency, knownCurrency)
+	}
+}
+
+// validateAmount проверяет, что сумма неотрицательна и, если checkCurrency, представима в валюте currency.
+func validateAmount(v *validator, field string, amount money.Amount, currency string, checkCurrency bool) {
+	if amount < 0 {
+		v.add(field, "must not be negative")
+		return
+	}
+
+	if !checkCurrency {
+		return
 	}
 
-	return nil
+	err := amount.CheckCurrency(currency)
+	if err != nil {
+		v.add(field, "%s", err)
+	}
 }
 
 type Item struct {
-	ChrtID      int64   `json:"chrt_id" db:"chrt_id"`
-	TrackNumber string  `json:"track_number" db:"track_number"`
-	Price       float64 `json:"price" db:"price"`
-	RID         string  `json:"rid" db:"rid"`
-	Name        string  `json:"name" db:"name"`
-	Sale        float64 `json:"sale" db:"sale"`
-	Size        string  `json:"size" db:"size"`
-	TotalPrice  float64 `json:"total_price" db:"total_price"`
-	NmID        int64   `json:"nm_id" db:"nm_id"`
-	Brand       string  `json:"brand" db:"brand"`
-	Status      int     `json:"status" db:"status"`
-	OrderUID    string  `json:"-" db:"order_uid"`
+	ChrtID      int64        `json:"chrt_id" db:"chrt_id"`
+	TrackNumber string       `json:"track_number" db:"track_number"`
+	Price       money.Amount `json:"price" db:"price"`
+	RID         string       `json:"rid" db:"rid"`
+	Name        string       `json:"name" db:"name"`
+	Sale        float64      `json:"sale" db:"sale"`
+	Size        string       `json:"size" db:"size"`
+	TotalPrice  money.Amount `json:"total_price" db:"total_price"`
+	NmID        int64        `json:"nm_id" db:"nm_id"`
+	Brand       string       `json:"brand" db:"brand"`
+	Status      int          `json:"status" db:"status"`
+	OrderUID    string       `json:"-" db:"order_uid"`
 }
 
 func (i *Item) Validate() error {
+	var v validator
+	i.validate(&v, "", "")
+	return v.err()
+}
+
+// validate проверяет товар. Суммы проверяются на представимость в валюте currency, если она известна.
+func (i *Item) validate(v *validator, prefix, currency string) {
 	if i.ChrtID == 0 {
-		return errors.New("illegal chrt_id (0)")
+		v.add(fieldPath(prefix, "chrt_id"), "must not be 0")
 	}
 
-	return nil
+	v.notEmpty(fieldPath(prefix, "track_number"), i.TrackNumber)
+
+	knownCurrency := money.KnownCurrency(currency)
+	validateAmount(v, fieldPath(prefix, "price"), i.Price, currency, knownCurrency)
+	validateAmount(v, fieldPath(prefix, "total_price"), i.TotalPrice, currency, knownCurrency)
 }
@@ -3,15 +3,51 @@ package order
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"wb-l0/pkg/httperrors"
 )
 
 var (
-	ErrNotFound = httperrors.NewHttpError("order with provided UID was not found in repository", http.StatusNotFound)
+	ErrNotFound      = httperrors.NewHttpError("order with provided UID was not found in repository", http.StatusNotFound)
+	ErrAlreadyExists = httperrors.NewHttpError("order with provided UID already exists in repository", http.StatusConflict)
 )
 
 type Repository interface {
 	GetOrder(ctx context.Context, uid string) (*Order, error)
 	CreateOrder(ctx context.Context, order *Order) error
 }
+
+// BatchCreator - хранилище, в которое можно сохранять заказы пакетами.
+type BatchCreator interface {
+	// CreateOrders сохраняет заказы и возвращает ошибки сохранения каждого из них в порядке orders. Уже сохранённые
+	// заказы не перезаписываются, для них возвращается ErrAlreadyExists. Ошибка err означает, что пакет
+	// не сохранён целиком. messages - исходные сообщения заказов в порядке orders или nil.
+	CreateOrders(ctx context.Context, orders []*Order, messages []*RawMessage) ([]error, error)
+}
+
+// Cache - репозиторий, используемый в качестве кэша, из которого можно удалять устаревшие записи.
+type Cache interface {
+	Repository
+	DeleteOrder(ctx context.Context, uid string) error
+}
+
+// Invalidator - то, что хранит копии заказов и умеет их сбрасывать при изменении заказов в основной базе данных.
+type Invalidator interface {
+	// InvalidateOrder сбрасывает сохранённую копию заказа с указанным UID.
+	InvalidateOrder(ctx context.Context, uid string) error
+	// InvalidateAll сбрасывает все сохранённые копии, например, если часть уведомлений об изменениях была потеряна.
+	InvalidateAll(ctx context.Context) error
+}
+
+// ChangeTracker - хранилище, которое может сообщить, какие заказы изменились начиная с указанного момента.
+type ChangeTracker interface {
+	ChangedOrderUIDs(ctx context.Context, since time.Time) ([]string, error)
+}
+
+// Purger - хранилище, из которого можно удалять устаревшие заказы.
+type Purger interface {
+	// PurgeOrders удаляет не более limit заказов, созданных раньше before, и возвращает их UID.
+	// Если archive равен true, заказы перед удалением архивируются.
+	PurgeOrders(ctx context.Context, before time.Time, limit int, archive bool) ([]string, error)
+}
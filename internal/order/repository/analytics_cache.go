@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"wb-l0/internal/order"
+)
+
+// CachedAnalytics - декоратор над order.Analytics, запоминающий результаты запросов на время ttl. Показатели
+// продаж считаются по большому количеству заказов, поэтому повторять расчёт на каждый запрос дашборда не нужно.
+type CachedAnalytics struct {
+	analytics order.Analytics
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*analyticsEntry
+}
+
+type analyticsEntry struct {
+	rows      []*order.SalesRow
+	expiresAt time.Time
+}
+
+func NewCachedAnalytics(analytics order.Analytics, ttl time.Duration) *CachedAnalytics {
+	return &CachedAnalytics{
+		analytics: analytics,
+		ttl:       ttl,
+		entries:   make(map[string]*analyticsEntry),
+	}
+}
+
+func (c *CachedAnalytics) Sales(ctx context.Context, query order.SalesQuery) ([]*order.SalesRow, error) {
+	key := fmt.Sprintf("%d:%d:%v", query.From.UnixNano(), query.To.UnixNano(), query.GroupBy)
+	if rows, ok := c.get(key); ok {
+		return rows, nil
+	}
+
+	rows, err := c.analytics.Sales(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.set(key, rows)
+	return rows, nil
+}
+
+func (c *CachedAnalytics) get(key string) ([]*order.SalesRow, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.rows, true
+}
+
+func (c *CachedAnalytics) set(key string, rows []*order.SalesRow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Количество различных запросов невелико, поэтому устаревшие записи удаляются полным просмотром.
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = &analyticsEntry{rows: rows, expiresAt: now.Add(c.ttl)}
+}
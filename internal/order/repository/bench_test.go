@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/conformance"
+	"wb-l0/internal/pgtest"
+)
+
+// benchOrders - количество заказов, среди которых случайно выбираются читаемые в бенчмарках.
+const benchOrders = 1000
+
+func benchOrderUIDs() []string {
+	uids := make([]string, benchOrders)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("bench-%d", i)
+	}
+
+	return uids
+}
+
+// fillRepository сохраняет в репозиторий заказы с указанными UID.
+func fillRepository(b *testing.B, repo order.Repository, uids []string) {
+	b.Helper()
+
+	for i, uid := range uids {
+		err := repo.CreateOrder(context.Background(), conformance.SampleOrder(uid, int64(i+1)*10))
+		if err != nil {
+			b.Fatalf("error creating order %s: %s", uid, err)
+		}
+	}
+}
+
+// benchmarkReads читает из репозитория случайные заказы из uids параллельно в GOMAXPROCS горутинах.
+func benchmarkReads(b *testing.B, repo order.Repository, uids []string) {
+	b.Helper()
+
+	ctx := context.Background()
+	var seed atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		random := rand.New(rand.NewSource(seed.Add(1)))
+		for pb.Next() {
+			_, err := repo.GetOrder(ctx, uids[random.Intn(len(uids))])
+			if err != nil {
+				b.Errorf("error getting order: %s", err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkGetOrderPostgres сравнивает способы чтения заказа из Postgres (см. ReadMode).
+func BenchmarkGetOrderPostgres(b *testing.B) {
+	cfg := pgtest.NewDatabase(b)
+	pool, err := NewPostgresPool(context.Background(), cfg.URL, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(pool.Close)
+
+	uids := benchOrderUIDs()
+	fillRepository(b, NewPostgresRepository(pool, ReadModeAggregated, nil), uids)
+
+	for _, mode := range []ReadMode{ReadModeTwoQueries, ReadModeAggregated} {
+		b.Run(string(mode), func(b *testing.B) {
+			benchmarkReads(b, NewPostgresRepository(pool, mode, nil), uids)
+		})
+	}
+}
+
+// BenchmarkGetOrderCached сравнивает чтение заказа из кэшей разных уровней.
+func BenchmarkGetOrderCached(b *testing.B) {
+	uids := benchOrderUIDs()
+	database := NewInMemoryRepository()
+	fillRepository(b, database, uids)
+
+	b.Run("memory", func(b *testing.B) {
+		cache := NewInMemoryRepository()
+		cached := NewCachedRepository("memory", database, cache)
+		fillRepository(b, cache, uids)
+		benchmarkReads(b, cached, uids)
+	})
+
+	b.Run("redis", func(b *testing.B) {
+		_, redisRepo := newTestRedis(b, 0)
+		cached := NewCachedRepository("redis", database, redisRepo)
+		fillRepository(b, redisRepo, uids)
+		benchmarkReads(b, cached, uids)
+	})
+
+	b.Run("redis-unavailable", func(b *testing.B) {
+		server, redisRepo := newTestRedis(b, time.Hour)
+		cached := NewCachedRepository("redis", database, redisRepo)
+		server.Close()
+		_, _ = redisRepo.GetOrder(context.Background(), uids[0])
+		benchmarkReads(b, cached, uids)
+	})
+
+	b.Run("negative", func(b *testing.B) {
+		cached := NewCachedRepositoryFromConfig(
+			"memory", NewInMemoryRepository(), NewInMemoryRepository(),
+			config.Cache{NegativeTTL: time.Hour, NegativeSize: benchOrders},
+		)
+
+		ctx := context.Background()
+		for _, uid := range uids {
+			_, _ = cached.GetOrder(ctx, uid)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = cached.GetOrder(ctx, uids[i%len(uids)])
+		}
+	})
+}
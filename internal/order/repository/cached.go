@@ -5,8 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync/atomic"
 
+	"wb-l0/internal/config"
 	"wb-l0/internal/order"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // CachedRepository - репозиторий, который работает с двумя другими репозиториями. Один из них считается
@@ -17,22 +23,60 @@ import (
 // При возникновении ошибок (в т.ч. если значение не найдено) производится запрос к основной базе данных,
 // и значение возвращается оттуда, при этом оно помещается в кэш для ускорения работы последующих запросов.
 // При отсутствии нужных данных в основной базе данных возвращается ошибка order.ErrNotFound.
+//
+// Отсутствие заказа в основной базе данных также может кэшироваться на короткое время, чтобы запросы
+// несуществующих UID не доходили до неё. Такая запись удаляется сразу же после сохранения заказа с этим UID.
+//
+// Заказ, прочитанный из основной базы данных, не помещается в кэш, если во время чтения кэш инвалидировался:
+// прочитанная копия могла устареть. Для этого, как и в negativeCache, ведётся счётчик инвалидаций (эпоха).
+//
+// Попадания и промахи кэша учитываются в метриках под именем name.
 type CachedRepository struct {
+	name     string
 	database order.Repository
-	cache    order.Repository
+	cache    order.Cache
+	negative *negativeCache
+	epoch    atomic.Uint64
 }
 
-func NewCachedRepository(database order.Repository, cache order.Repository) *CachedRepository {
-	return &CachedRepository{database: database, cache: cache}
+func NewCachedRepository(name string, database order.Repository, cache order.Cache) *CachedRepository {
+	return &CachedRepository{name: name, database: database, cache: cache}
+}
+
+func NewCachedRepositoryFromConfig(
+	name string, database order.Repository, cache order.Cache, cfg config.Cache,
+) *CachedRepository {
+	return &CachedRepository{
+		name:     name,
+		database: database,
+		cache:    cache,
+		negative: newNegativeCache(cfg.NegativeTTL, cfg.NegativeSize),
+	}
 }
 
 func (c *CachedRepository) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
+	// Обращения к нижележащим репозиториям при промахе попадают в трассировку как дочерние спаны.
+	ctx, span := tracer.Start(ctx, c.name+".cache_lookup", trace.WithAttributes(
+		attribute.String("cache", c.name),
+		attribute.String("order.uid", uid),
+	))
+	defer span.End()
+
+	// Недавно уже выяснили, что такого заказа нет.
+	if c.negative.contains(uid) {
+		c.count(span, cacheResultNegativeHit)
+		return nil, order.ErrNotFound
+	}
+
 	// Пробуем получить значение из кэша.
 	o, err := c.cache.GetOrder(ctx, uid)
 	if err == nil {
+		c.count(span, cacheResultHit)
 		return o, nil
 	}
 
+	c.count(span, cacheResultMiss)
+
 	// Проверяем, столкнулись мы с реальной ошибкой или же просто не смогли найти нужное значение.
 	// Логируем ошибку, если она не связана с тем, что отсутствует значение в базе данных.
 	if !errors.Is(err, order.ErrNotFound) {
@@ -40,26 +84,70 @@ func (c *CachedRepository) GetOrder(ctx context.Context, uid string) (*order.Ord
 	}
 
 	// Пробуем получить значение из основной базы данных.
+	negativeEpoch := c.negative.currentEpoch()
+	epoch := c.epoch.Load()
 	o, err = c.database.GetOrder(ctx, uid)
 	if err != nil {
 		if errors.Is(err, order.ErrNotFound) {
+			c.negative.add(uid, negativeEpoch)
 			return nil, order.ErrNotFound
 		}
 
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("error fetching order %s from database: %s", uid, err)
 	}
 
-	// Сохраняем полученной из основной базы данных значение в кэш.
-	_ = c.cache.CreateOrder(ctx, o)
+	c.fill(ctx, span, o, epoch)
 	return o, nil
 }
 
+// fill сохраняет полученный из основной базы данных заказ в кэш, если с момента получения epoch не было
+// инвалидаций. Инвалидация могла произойти и во время сохранения, поэтому после него эпоха проверяется
+// повторно и при её смене заказ удаляется из кэша. Заказ мог быть одновременно сохранён в кэш другим запросом,
+// это не ошибка.
+func (c *CachedRepository) fill(ctx context.Context, span trace.Span, o *order.Order, epoch uint64) {
+	if c.epoch.Load() != epoch {
+		return
+	}
+
+	err := c.cache.CreateOrder(ctx, o)
+	if err != nil {
+		if !errors.Is(err, order.ErrAlreadyExists) {
+			log.Printf("error saving order %s to cache: %s\n", o.OrderUID, err)
+		}
+		return
+	}
+
+	if c.epoch.Load() != epoch {
+		err = c.cache.DeleteOrder(ctx, o.OrderUID)
+		if err != nil {
+			log.Printf("error deleting stale order %s from cache: %s\n", o.OrderUID, err)
+		}
+		return
+	}
+
+	c.count(span, cacheResultFill)
+}
+
 func (c *CachedRepository) CreateOrder(ctx context.Context, o *order.Order) error {
-	err := c.database.CreateOrder(ctx, o)
+	return c.CreateOrderWithMessage(ctx, o, nil)
+}
+
+// CreateOrderWithMessage сохраняет заказ так же, как CreateOrder, передавая исходное сообщение основной базе
+// данных. В кэш сохраняется только заказ.
+func (c *CachedRepository) CreateOrderWithMessage(ctx context.Context, o *order.Order, message *order.RawMessage) error {
+	err := order.CreateOrderWithMessage(ctx, c.database, o, message)
+	if errors.Is(err, order.ErrAlreadyExists) {
+		return err
+	}
+
 	if err != nil {
 		return fmt.Errorf("error saving order %s to database: %s", o.OrderUID, err)
 	}
 
+	c.negative.invalidate(o.OrderUID)
+
 	err = c.cache.CreateOrder(ctx, o)
 	if err != nil {
 		log.Printf("error saving order %s to cache: %s\n", o.OrderUID, err)
@@ -67,3 +155,39 @@ func (c *CachedRepository) CreateOrder(ctx context.Context, o *order.Order) erro
 
 	return nil
 }
+
+// InvalidateOrder удаляет заказ из кэша. Если основная база данных сама является кэширующим репозиторием
+// (например, при многоуровневом кэшировании), заказ удаляется и из её кэша.
+func (c *CachedRepository) InvalidateOrder(ctx context.Context, uid string) error {
+	// Эпоха меняется до удаления из кэша, чтобы копия, прочитанная до изменения заказа, не вернулась в кэш.
+	c.epoch.Add(1)
+	c.negative.invalidate(uid)
+
+	err := c.cache.DeleteOrder(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("error deleting order %s from cache: %s", uid, err)
+	}
+
+	if invalidator, ok := c.database.(order.Invalidator); ok {
+		return invalidator.InvalidateOrder(ctx, uid)
+	}
+
+	return nil
+}
+
+// InvalidateAll очищает кэш, если он это поддерживает. Очищается только кэш верхнего уровня: общие для
+// нескольких экземпляров кэши нижних уровней не затрагиваются.
+func (c *CachedRepository) InvalidateAll(_ context.Context) error {
+	c.epoch.Add(1)
+	c.negative.clear()
+	if cache, ok := c.cache.(interface{ Clear() }); ok {
+		cache.Clear()
+	}
+
+	return nil
+}
+
+func (c *CachedRepository) count(span trace.Span, result string) {
+	span.AddEvent(result)
+	cacheRequests.WithLabelValues(c.name, result).Inc()
+}
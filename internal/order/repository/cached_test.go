@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"wb-l0/internal/order"
+)
+
+// invalidatingRepository перед возвратом заказа вызывает invalidate, имитируя изменение заказа во время чтения.
+type invalidatingRepository struct {
+	order.Repository
+	invalidate func(uid string)
+}
+
+func (r *invalidatingRepository) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
+	o, err := r.Repository.GetOrder(ctx, uid)
+	r.invalidate(uid)
+	return o, err
+}
+
+func TestCachedRepositorySkipsFillAfterInvalidation(t *testing.T) {
+	ctx := context.Background()
+	database, o := newTestDatabase(t, "stale")
+	cache := NewInMemoryRepository()
+	slow := &invalidatingRepository{Repository: database}
+	cached := NewCachedRepository("memory", slow, cache)
+	slow.invalidate = func(uid string) { _ = cached.InvalidateOrder(ctx, uid) }
+
+	_, err := cached.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order: %s", err)
+	}
+
+	if _, err := cache.GetOrder(ctx, o.OrderUID); !errors.Is(err, order.ErrNotFound) {
+		t.Errorf("got error %v from cache, want order.ErrNotFound: order read before invalidation is cached", err)
+	}
+
+	// Без инвалидации прочитанный заказ сохраняется в кэш.
+	slow.invalidate = func(string) {}
+	_, err = cached.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order: %s", err)
+	}
+
+	if _, err := cache.GetOrder(ctx, o.OrderUID); err != nil {
+		t.Errorf("order is not cached: %s", err)
+	}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order/conformance"
+	"wb-l0/internal/pgtest"
+)
+
+func TestConformanceInMemory(t *testing.T) {
+	conformance.Run(t, NewInMemoryRepository(), conformance.Options{})
+}
+
+func TestConformanceSQLite(t *testing.T) {
+	repo := newTestSQLite(t, filepath.Join(t.TempDir(), "orders.db"))
+	conformance.Run(t, repo, conformance.Options{})
+}
+
+func TestConformanceRedis(t *testing.T) {
+	_, repo := newTestRedis(t, 0)
+	conformance.Run(t, repo, conformance.Options{Cache: true})
+}
+
+func TestConformanceCached(t *testing.T) {
+	_, cache := newTestRedis(t, 0)
+	repo := NewCachedRepositoryFromConfig(
+		"conformance", NewInMemoryRepository(), cache,
+		config.Cache{NegativeTTL: time.Minute, NegativeSize: 1000},
+	)
+	conformance.Run(t, repo, conformance.Options{})
+}
+
+func TestConformancePostgres(t *testing.T) {
+	repo, err := NewPostgresRepositoryFromConfig(context.Background(), pgtest.NewDatabase(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(repo.pool.Close)
+
+	conformance.Run(t, repo, conformance.Options{})
+}
+
+// newTestSharded создаёт репозиторий из двух шардов на временных базах данных с каталогом в шарде "a".
+// Проверочные заказы сохраняются в шард "b".
+func newTestSharded(t *testing.T) *ShardedRepository {
+	t.Helper()
+
+	a, b := pgtest.NewDatabase(t), pgtest.NewDatabase(t)
+	repo, err := NewShardedRepositoryFromConfig(context.Background(), config.Sharding{
+		Shards:         map[string]string{"a": a.URL, "b": b.URL},
+		Keys:           map[string]string{conformance.SampleOrder("", 0).ShardKey: "b"},
+		DefaultShard:   "a",
+		Locator:        "directory",
+		DirectoryShard: "a",
+	}, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(repo.close)
+
+	return repo
+}
+
+func TestConformanceSharded(t *testing.T) {
+	conformance.Run(t, newTestSharded(t), conformance.Options{})
+}
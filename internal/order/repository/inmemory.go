@@ -2,8 +2,8 @@ package repository
 
 import (
 	"context"
-	"errors"
 	"sync"
+	"time"
 
 	"wb-l0/internal/order"
 )
@@ -28,11 +28,56 @@ func (i *InMemoryRepository) GetOrder(_ context.Context, uid string) (*order.Ord
 	return value.(*order.Order), nil
 }
 
+// CreateOrder сохраняет заказ. Если заказ с таким UID уже сохранён, возвращает order.ErrAlreadyExists.
 func (i *InMemoryRepository) CreateOrder(_ context.Context, o *order.Order) error {
-	if _, ok := i.store.Load(o.OrderUID); ok {
-		return errors.New("order with the same id already exists")
+	if _, loaded := i.store.LoadOrStore(o.OrderUID, o); loaded {
+		return order.ErrAlreadyExists
 	}
 
-	i.store.Store(o.OrderUID, o)
 	return nil
 }
+
+func (i *InMemoryRepository) DeleteOrder(_ context.Context, uid string) error {
+	i.store.Delete(uid)
+	return nil
+}
+
+// ScanOrderUIDs передаёт fn UID сохранённых заказов: всех или, если limit больше нуля, не более limit
+// произвольных.
+func (i *InMemoryRepository) ScanOrderUIDs(ctx context.Context, limit int, fn func(uid string) error) error {
+	var err error
+	scanned := 0
+	i.store.Range(func(key, _ any) bool {
+		err = ctx.Err()
+		if err == nil {
+			err = fn(key.(string))
+		}
+
+		scanned++
+		return err == nil && (limit <= 0 || scanned < limit)
+	})
+
+	return err
+}
+
+// Clear удаляет из репозитория все заказы.
+func (i *InMemoryRepository) Clear() {
+	i.store.Range(func(key, _ any) bool {
+		i.store.Delete(key)
+		return true
+	})
+}
+
+// DeleteCreatedBefore удаляет из репозитория заказы, созданные раньше before, и возвращает их количество.
+func (i *InMemoryRepository) DeleteCreatedBefore(before time.Time) int {
+	deleted := 0
+	i.store.Range(func(key, value any) bool {
+		if value.(*order.Order).DateCreated.Before(before) {
+			i.store.Delete(key)
+			deleted++
+		}
+		return true
+	})
+
+	return deleted
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	listenerMinBackoff = time.Second
+	listenerMaxBackoff = 30 * time.Second
+)
+
+// PostgresListener - подписчик на уведомления об изменении заказов, отправляемые PostgresRepository.
+// Для каждого уведомления сбрасывает копию заказа в локальном кэше.
+//
+// Слушает канал через отдельное соединение и переподключается при его потере. Уведомления, отправленные
+// в момент отсутствия соединения, теряются, поэтому после переподключения кэш сбрасывается целиком.
+type PostgresListener struct {
+	url         string
+	channel     string
+	invalidator order.Invalidator
+}
+
+func NewPostgresListener(url string, channel string, invalidator order.Invalidator) *PostgresListener {
+	return &PostgresListener{
+		url:         url,
+		channel:     channel,
+		invalidator: invalidator,
+	}
+}
+
+func NewPostgresListenerFromConfig(cfg config.PostgresConnection, invalidator order.Invalidator) *PostgresListener {
+	return NewPostgresListener(cfg.URL, OrderChangesChannel, invalidator)
+}
+
+// Run слушает уведомления до отмены контекста.
+func (l *PostgresListener) Run(ctx context.Context) {
+	backoff := listenerMinBackoff
+	connectedBefore := false
+
+	for {
+		err := l.listen(ctx, func() {
+			if connectedBefore {
+				err := l.invalidator.InvalidateAll(ctx)
+				if err != nil {
+					log.Println("error invalidating cache after reconnect:", err)
+				}
+			}
+
+			connectedBefore = true
+			backoff = listenerMinBackoff
+			log.Printf("listening for order changes on channel \"%s\"\n", l.channel)
+		})
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("order changes listener failed: %s, reconnecting in %s\n", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, listenerMaxBackoff)
+	}
+}
+
+func (l *PostgresListener) listen(ctx context.Context, onListening func()) error {
+	conn, err := pgx.Connect(ctx, l.url)
+	if err != nil {
+		return fmt.Errorf("error connecting to postgres: %s", err)
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "listen "+pgx.Identifier{l.channel}.Sanitize())
+	if err != nil {
+		return fmt.Errorf("error subscribing to channel: %s", err)
+	}
+
+	onListening()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("error waiting for notification: %s", err)
+		}
+
+		err = l.invalidator.InvalidateOrder(ctx, notification.Payload)
+		if err != nil {
+			log.Printf("error invalidating order %s: %s\n", notification.Payload, err)
+		}
+	}
+}
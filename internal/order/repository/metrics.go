@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"wb-l0/internal/order"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("wb-l0/internal/order/repository")
+
+var (
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "wbl0",
+		Subsystem: "repository",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of order repository operations.",
+	}, []string{"repository", "operation"})
+
+	operationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "repository",
+		Name:      "operation_errors_total",
+		Help:      "Number of failed order repository operations. Missing orders are not counted as errors.",
+	}, []string{"repository", "operation"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "wbl0",
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Number of cache lookups by result: hit, negative_hit, miss, fill.",
+	}, []string{"cache", "result"})
+)
+
+const (
+	cacheResultHit         = "hit"
+	cacheResultNegativeHit = "negative_hit"
+	cacheResultMiss        = "miss"
+	cacheResultFill        = "fill"
+)
+
+// MetricsRepository - декоратор над order.Repository, записывающий в метрики Prometheus время выполнения
+// и количество ошибок каждой операции. Каждая операция также оборачивается в спан трассировки.
+// Репозитории различаются в метриках и трассировках по имени.
+type MetricsRepository struct {
+	repository order.Repository
+	name       string
+}
+
+func NewMetricsRepository(repository order.Repository, name string) *MetricsRepository {
+	return &MetricsRepository{repository: repository, name: name}
+}
+
+func (m *MetricsRepository) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
+	ctx, finish := m.start(ctx, "get_order", uid)
+	o, err := m.repository.GetOrder(ctx, uid)
+	finish(err)
+	return o, err
+}
+
+func (m *MetricsRepository) CreateOrder(ctx context.Context, o *order.Order) error {
+	ctx, finish := m.start(ctx, "create_order", o.OrderUID)
+	err := m.repository.CreateOrder(ctx, o)
+	finish(err)
+	return err
+}
+
+// CreateOrderWithMessage сохраняет заказ вместе с исходным сообщением, если декорируемый репозиторий
+// это поддерживает (см. order.CreateOrderWithMessage).
+func (m *MetricsRepository) CreateOrderWithMessage(ctx context.Context, o *order.Order, message *order.RawMessage) error {
+	ctx, finish := m.start(ctx, "create_order", o.OrderUID)
+	err := order.CreateOrderWithMessage(ctx, m.repository, o, message)
+	finish(err)
+	return err
+}
+
+// start начинает спан операции и возвращает функцию, которая завершает его и записывает метрики.
+func (m *MetricsRepository) start(ctx context.Context, operation string, uid string) (context.Context, func(err error)) {
+	startedAt := time.Now()
+	ctx, span := tracer.Start(ctx, m.name+"."+operation, trace.WithAttributes(
+		attribute.String("repository", m.name),
+		attribute.String("order.uid", uid),
+	))
+
+	return ctx, func(err error) {
+		m.observe(operation, startedAt)
+		m.countError(operation, err)
+
+		if errors.Is(err, order.ErrNotFound) {
+			span.SetAttributes(attribute.Bool("order.found", false))
+		} else if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+	}
+}
+
+func (m *MetricsRepository) observe(operation string, start time.Time) {
+	operationDuration.WithLabelValues(m.name, operation).Observe(time.Since(start).Seconds())
+}
+
+func (m *MetricsRepository) countError(operation string, err error) {
+	if err != nil && !errors.Is(err, order.ErrNotFound) {
+		operationErrors.WithLabelValues(m.name, operation).Inc()
+	}
+}
+
+// MetricsCache - то же, что MetricsRepository, но для кэша: дополнительно измеряется удаление заказов.
+type MetricsCache struct {
+	MetricsRepository
+	cache order.Cache
+}
+
+func NewMetricsCache(cache order.Cache, name string) *MetricsCache {
+	return &MetricsCache{
+		MetricsRepository: MetricsRepository{repository: cache, name: name},
+		cache:             cache,
+	}
+}
+
+func (m *MetricsCache) DeleteOrder(ctx context.Context, uid string) error {
+	ctx, finish := m.start(ctx, "delete_order", uid)
+	err := m.cache.DeleteOrder(ctx, uid)
+	finish(err)
+	return err
+}
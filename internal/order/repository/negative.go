@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// negativeCache - ограниченный по размеру кэш отрицательных результатов поиска, т.е. UID заказов, которых
+// не оказалось в основной базе данных. Записи живут не дольше ttl, при переполнении вытесняются самые старые.
+//
+// Чтобы результат запроса, начатого до сохранения заказа, не скрыл только что сохранённый заказ, кэш ведёт
+// счётчик инвалидаций (эпоху). Отрицательный результат запоминается только если с момента начала запроса
+// к базе данных не было ни одной инвалидации.
+//
+// Нулевой указатель на negativeCache является корректным выключенным кэшем.
+type negativeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	epoch   uint64
+	entries map[string]*list.Element
+	queue   *list.List
+}
+
+type negativeEntry struct {
+	uid       string
+	expiresAt time.Time
+}
+
+func newNegativeCache(ttl time.Duration, size int) *negativeCache {
+	if ttl <= 0 || size <= 0 {
+		return nil
+	}
+
+	return &negativeCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		queue:   list.New(),
+	}
+}
+
+// contains проверяет, известно ли, что заказа с указанным UID нет в базе данных.
+func (n *negativeCache) contains(uid string) bool {
+	if n == nil {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	element, ok := n.entries[uid]
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(element.Value.(*negativeEntry).expiresAt) {
+		n.remove(element)
+		return false
+	}
+
+	return true
+}
+
+// currentEpoch возвращает текущую эпоху, которую нужно передать в add после запроса к базе данных.
+func (n *negativeCache) currentEpoch() uint64 {
+	if n == nil {
+		return 0
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.epoch
+}
+
+// add запоминает отсутствие заказа, если с момента получения epoch не было инвалидаций.
+func (n *negativeCache) add(uid string, epoch uint64) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if epoch != n.epoch {
+		return
+	}
+
+	if element, ok := n.entries[uid]; ok {
+		n.remove(element)
+	}
+
+	for n.queue.Len() >= n.size {
+		n.remove(n.queue.Front())
+	}
+
+	entry := &negativeEntry{uid: uid, expiresAt: time.Now().Add(n.ttl)}
+	n.entries[uid] = n.queue.PushBack(entry)
+}
+
+// invalidate удаляет запись об отсутствии заказа и начинает новую эпоху.
+func (n *negativeCache) invalidate(uid string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.epoch++
+	if element, ok := n.entries[uid]; ok {
+		n.remove(element)
+	}
+}
+
+// clear удаляет все записи и начинает новую эпоху.
+func (n *negativeCache) clear() {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.epoch++
+	n.entries = make(map[string]*list.Element)
+	n.queue.Init()
+}
+
+func (n *negativeCache) remove(element *list.Element) {
+	delete(n.entries, element.Value.(*negativeEntry).uid)
+	n.queue.Remove(element)
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"fmt"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/envelope"
+)
+
+// deliveryPII возвращает указатели на поля доставки, содержащие персональные данные, вместе с их именами,
+// которые используются как дополнительные данные при шифровании.
+func deliveryPII(delivery *order.Delivery) map[string]*string {
+	return map[string]*string{
+		"delivery.name":    &delivery.Name,
+		"delivery.phone":   &delivery.Phone,
+		"delivery.address": &delivery.Address,
+		"delivery.email":   &delivery.Email,
+	}
+}
+
+// sealDelivery возвращает копию доставки, в которой персональные данные зашифрованы.
+func sealDelivery(sealer *envelope.Sealer, delivery *order.Delivery) (*order.Delivery, error) {
+	sealed := *delivery
+	for field, value := range deliveryPII(&sealed) {
+		ciphertext, err := sealer.Seal(*value, field)
+		if err != nil {
+			return nil, fmt.Errorf("error encrypting %s: %s", field, err)
+		}
+
+		*value = ciphertext
+	}
+
+	return &sealed, nil
+}
+
+// openDelivery расшифровывает персональные данные доставки на месте.
+func openDelivery(sealer *envelope.Sealer, delivery *order.Delivery) error {
+	if delivery == nil {
+		return nil
+	}
+
+	for field, value := range deliveryPII(delivery) {
+		plaintext, err := sealer.Open(*value, field)
+		if err != nil {
+			return fmt.Errorf("error decrypting %s: %s", field, err)
+		}
+
+		*value = plaintext
+	}
+
+	return nil
+}
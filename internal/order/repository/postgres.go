@@ -4,39 +4,134 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"wb-l0/internal/config"
 	"wb-l0/internal/order"
+	"wb-l0/pkg/envelope"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// PostgresRepository - обёртка над соединением pgx, позволяющая сохранять в базе данных представленные в виде структур
+// PostgresRepository - обёртка над пулом соединений pgx, позволяющая сохранять в базе данных представленные в виде структур
 // Go сущности, связанные с заказами, а также получать их. Использует четыре таблицы:
 //
 // orders непосредственно для хранения самих заказов;
 // deliveries для хранения информации о доставках: orders.delivery_id -> deliveries.id;
 // payments для хранения информации о платежах: orders.transaction -> payments.transaction;
 // items для хранения самих товаров: items.order_uid -> orders.order_uid.
+//
+// При каждом изменении заказа в канал OrderChangesChannel отправляется уведомление с его UID, чтобы другие
+// экземпляры сервиса могли сбросить свои копии заказа (см. PostgresListener).
+//
+// Персональные данные доставки и тела исходных сообщений шифруются перед сохранением, если задан sealer.
+//
+// Если заданы реплики, заказы и исходные сообщения читаются с них (см. replicaSet), запись и служебные
+// запросы всегда выполняются на ведущем сервере.
 type PostgresRepository struct {
-	conn *pgx.Conn
+	pool     *pgxpool.Pool
+	replicas *replicaSet
+	readMode ReadMode
+	sealer   *envelope.Sealer
+}
+
+// ReadMode - способ получения заказа из базы данных.
+type ReadMode string
+
+const (
+	// ReadModeAggregated - заказ вместе с товарами получается одним запросом, товары собираются в JSON-массив
+	// на стороне Postgres.
+	ReadModeAggregated ReadMode = "aggregated"
+	// ReadModeTwoQueries - заказ и товары получаются двумя запросами в одной транзакции.
+	ReadModeTwoQueries ReadMode = "two-queries"
+)
+
+func NewPostgresRepository(pool *pgxpool.Pool, readMode ReadMode, sealer *envelope.Sealer) *PostgresRepository {
+	return &PostgresRepository{pool: pool, readMode: readMode, sealer: sealer}
+}
+
+func NewPostgresRepositoryFromConfig(
+	ctx context.Context, cfg config.PostgresConnection, sealer *envelope.Sealer,
+) (*PostgresRepository, error) {
+	pool, err := NewPostgresPool(ctx, cfg.URL, cfg.MaxConns)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode := ReadMode(cfg.ReadMode)
+	if readMode != ReadModeAggregated && readMode != ReadModeTwoQueries {
+		pool.Close()
+		return nil, fmt.Errorf("unknown postgres read mode \"%s\"", cfg.ReadMode)
+	}
+
+	replicas, err := newReplicaSetFromConfig(ctx, cfg)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	repository := NewPostgresRepository(pool, readMode, sealer)
+	repository.replicas = replicas
+	return repository, nil
+}
+
+// RunReplicaHealthChecks периодически проверяет доступность реплик до отмены контекста. Если реплики не заданы,
+// сразу же возвращает управление.
+func (r *PostgresRepository) RunReplicaHealthChecks(ctx context.Context) {
+	r.replicas.run(ctx)
 }
 
-func NewPostgresRepository(conn *pgx.Conn) *PostgresRepository {
-	return &PostgresRepository{conn: conn}
+// Primary возвращает репозиторий, который читает только с ведущего сервера. Он нужен там, где чтение устаревшей
+// копии с реплики недопустимо, например, при сверке кэшей. Записи через него не учитываются при выборе реплик
+// для чтения своих записей, поэтому сохранять через него заказы не следует.
+func (r *PostgresRepository) Primary() *PostgresRepository {
+	return NewPostgresRepository(r.pool, r.readMode, r.sealer)
 }
 
-func NewPostgresRepositoryFromConfig(ctx context.Context, cfg config.PostgresConnection) (*PostgresRepository, error) {
-	conn, err := pgx.Connect(ctx, cfg.URL)
+// NewPostgresPool создаёт пул соединений. Запросы без явной подготовки всё равно подготавливаются pgx
+// и кэшируются для каждого соединения, поэтому повторные запросы не разбираются Postgres заново.
+// Нулевое значение maxConns оставляет размер пула по умолчанию.
+func NewPostgresPool(ctx context.Context, url string, maxConns int) (*pgxpool.Pool, error) {
+	poolConfig, err := newPoolConfig(url, maxConns)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing postgres url: %s", err)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to postgres: %s", err)
 	}
-	return NewPostgresRepository(conn), nil
+
+	err = pool.Ping(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("error connecting to postgres: %s", err)
+	}
+
+	return pool, nil
 }
 
-const getOrderQuery string = `
-select o.order_uid,
+func newPoolConfig(url string, maxConns int) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxConns > 0 {
+		poolConfig.MaxConns = int32(maxConns)
+	}
+
+	poolConfig.ConnConfig.Tracer = queryTracer{}
+	return poolConfig, nil
+}
+
+// OrderChangesChannel - канал LISTEN/NOTIFY, в который отправляются UID изменённых заказов.
+const OrderChangesChannel = "order_changes"
+
+const orderColumns = `
+       o.order_uid,
        o.track_number,
        o.entry,
        o.locale,
@@ -47,6 +142,9 @@ select o.order_uid,
        o.sm_id,
        o.date_created,
        o.oof_shard,
+       o.state,
+       o.state_changed_at,
+       o.version,
        d.name as "delivery.name",
        d.phone as "delivery.phone",
        d.zip as "delivery.zip",
@@ -63,10 +161,36 @@ select o.order_uid,
        p.bank as "payment.bank",
        p.delivery_cost as "payment.delivery_cost",
        p.goods_total as "payment.goods_total",
-       p.custom_fee as "payment.custom_fee"
+       p.custom_fee as "payment.custom_fee"`
+
+const orderJoins = `
 from orders o
          join deliveries d on d.id = o.delivery_id
-         join payments p on p.transaction = o.transaction
+         join payments p on p.transaction = o.transaction`
+
+const getOrderQuery = `select` + orderColumns + orderJoins + `
+where o.order_uid = $1`
+
+// orderItemsAggregate - подзапрос, собирающий товары заказа o в JSON-массив. Ключи объектов совпадают
+// с JSON-тегами order.Item.
+const orderItemsAggregate = `
+       coalesce((select json_agg(json_build_object(
+                   'chrt_id', i.chrt_id,
+                   'track_number', i.track_number,
+                   'price', i.price,
+                   'rid', i.rid,
+                   'name', i.name,
+                   'sale', i.sale,
+                   'size', i.size,
+                   'total_price', i.total_price,
+                   'nm_id', i.nm_id,
+                   'brand', i.brand,
+                   'status', i.status
+                 ) order by i.chrt_id)
+                 from items i
+                 where i.order_uid = o.order_uid), '[]') as items`
+
+const getOrderAggregatedQuery = `select` + orderColumns + `,` + orderItemsAggregate + orderJoins + `
 where o.order_uid = $1`
 
 const getOrderItemsQuery = `
@@ -86,7 +210,85 @@ from items where order_uid = $1
 `
 
 func (r *PostgresRepository) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
-	tx, err := r.conn.Begin(ctx)
+	var o *order.Order
+	err := r.read(uid, func(pool *pgxpool.Pool) (err error) {
+		o, err = r.getOrderFrom(ctx, pool, uid)
+		return err
+	})
+
+	return o, err
+}
+
+// read выполняет чтение fn на одной из реплик, а если их нет, они недоступны или вернули ошибку - на ведущем
+// сервере. Отсутствие данных на реплике тоже проверяется на ведущем сервере: они могли ещё не дойти до реплики.
+func (r *PostgresRepository) read(uid string, fn func(pool *pgxpool.Pool) error) error {
+	if replica := r.replicas.pick(uid); replica != nil {
+		err := fn(replica.pool)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, order.ErrNotFound) {
+			replica.markUnhealthy(err)
+		}
+	}
+
+	return fn(r.pool)
+}
+
+func (r *PostgresRepository) getOrderFrom(ctx context.Context, pool *pgxpool.Pool, uid string) (*order.Order, error) {
+	var o *order.Order
+	var err error
+	if r.readMode == ReadModeTwoQueries {
+		o, err = r.getOrderTwoQueries(ctx, pool, uid)
+	} else {
+		o, err = r.getOrderAggregated(ctx, pool, uid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = openDelivery(r.sealer, o.Delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// aggregatedOrderRow - строка результата getOrderAggregatedQuery. Товары приходят в виде JSON-массива.
+type aggregatedOrderRow struct {
+	order.Order
+	Items []*order.Item `db:"items"`
+}
+
+func (*PostgresRepository) getOrderAggregated(ctx context.Context, db pgxscan.Querier, uid string) (*order.Order, error) {
+	var row aggregatedOrderRow
+	err := pgxscan.Get(ctx, db, &row, getOrderAggregatedQuery, uid)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, order.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("error fetching order from database: %s", err)
+	}
+
+	return row.order(), nil
+}
+
+func (row *aggregatedOrderRow) order() *order.Order {
+	o := row.Order
+	o.Items = row.Items
+	for _, item := range o.Items {
+		item.OrderUID = o.OrderUID
+	}
+
+	return &o
+}
+
+func (*PostgresRepository) getOrderTwoQueries(ctx context.Context, pool *pgxpool.Pool, uid string) (*order.Order, error) {
+	tx, err := pool.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %s", err)
 	}
@@ -117,16 +319,100 @@ func (r *PostgresRepository) GetOrder(ctx context.Context, uid string) (*order.O
 
 const createOrderQuery = `
 insert into orders
-    (order_uid, track_number, entry, delivery_id, transaction, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-    values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+    (order_uid, track_number, entry, delivery_id, transaction, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, state, state_changed_at)
+    values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+
+const (
+	// lockOrderUIDQuery блокирует UID заказа до конца транзакции, чтобы одновременные попытки сохранить один
+	// и тот же заказ выполнялись по очереди и проверка orderExistsQuery видела результат предыдущей.
+	lockOrderUIDQuery = `select pg_advisory_xact_lock(hashtextextended($1, 0))`
 
+	orderExistsQuery = `select exists(select 1 from orders where order_uid = $1)`
+)
+
+// CreateOrder сохраняет заказ. Если заказ с таким UID уже сохранён, возвращает order.ErrAlreadyExists.
 func (r *PostgresRepository) CreateOrder(ctx context.Context, o *order.Order) error {
-	tx, err := r.conn.Begin(ctx)
+	return r.CreateOrderWithMessage(ctx, o, nil)
+}
+
+// CreateOrderWithMessage сохраняет заказ и, если message не nil, исходное сообщение в одной транзакции.
+func (r *PostgresRepository) CreateOrderWithMessage(ctx context.Context, o *order.Order, message *order.RawMessage) error {
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %s", err)
 	}
 	defer tx.Rollback(ctx)
 
+	err = r.createOrder(ctx, tx, o, message)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("error committing transaction: %s", err)
+	}
+
+	return nil
+}
+
+// CreateOrders сохраняет заказы одной транзакцией, каждый - в своей точке сохранения, поэтому ошибка сохранения
+// одного заказа не отменяет сохранение остальных. Возвращает ошибки сохранения заказов в порядке orders;
+// если возвращена ошибка err, не сохранён ни один заказ.
+func (r *PostgresRepository) CreateOrders(
+	ctx context.Context, orders []*order.Order, messages []*order.RawMessage,
+) ([]error, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	errs := make([]error, len(orders))
+	for i, o := range orders {
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error creating savepoint: %s", err)
+		}
+
+		errs[i] = r.createOrder(ctx, savepoint, o, messageAt(messages, i))
+		if errs[i] != nil {
+			err = savepoint.Rollback(ctx)
+		} else {
+			err = savepoint.Commit(ctx)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error releasing savepoint: %s", err)
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error committing transaction: %s", err)
+	}
+
+	return errs, nil
+}
+
+func (r *PostgresRepository) createOrder(ctx context.Context, tx pgx.Tx, o *order.Order, message *order.RawMessage) error {
+	_, err := tx.Exec(ctx, lockOrderUIDQuery, o.OrderUID)
+	if err != nil {
+		return fmt.Errorf("error locking order uid: %s", err)
+	}
+
+	var exists bool
+	err = tx.QueryRow(ctx, orderExistsQuery, o.OrderUID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking order existence: %s", err)
+	}
+
+	if exists {
+		return order.ErrAlreadyExists
+	}
+
+	o.InitState(time.Now())
+	o.Version = 1
+
 	err = r.createPayment(ctx, tx, o.Payment)
 	if err != nil {
 		return fmt.Errorf("error saving payment in database: %s", err)
@@ -140,28 +426,59 @@ func (r *PostgresRepository) CreateOrder(ctx context.Context, o *order.Order) er
 	_, err = tx.Exec(
 		ctx, createOrderQuery,
 		o.OrderUID, o.TrackNumber, o.Entry, o.Delivery.ID, o.Payment.Transaction, o.Locale, o.InternalSignature,
-		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard,
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, o.State, o.StateChangedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("error saving order in database: %s", err)
 	}
 
+	err = r.createStateTransition(ctx, tx, o.OrderUID, &order.StateTransition{To: o.State, ChangedAt: o.StateChangedAt})
+	if err != nil {
+		return fmt.Errorf("error saving order state history: %s", err)
+	}
+
 	for _, item := range o.Items {
 		item.OrderUID = o.OrderUID
 		err := r.createItem(ctx, tx, item)
 		if err != nil {
-			return fmt.Errorf("error saving item %s in database: %s", item.ChrtID, err)
+			return fmt.Errorf("error saving item %d in database: %s", item.ChrtID, err)
+		}
+
+		err = r.createItemStatusRecord(ctx, tx, item)
+		if err != nil {
+			return fmt.Errorf("error saving item %d status history: %s", item.ChrtID, err)
 		}
 	}
 
-	err = tx.Commit(ctx)
+	if message != nil {
+		err := r.createRawMessage(ctx, tx, o.OrderUID, message)
+		if err != nil {
+			return fmt.Errorf("error saving raw message in database: %s", err)
+		}
+	}
+
+	err = r.createSearchDocument(ctx, tx, o)
 	if err != nil {
-		return fmt.Errorf("error committing transaction: %s", err)
+		return fmt.Errorf("error indexing order for search: %s", err)
+	}
+
+	err = r.orderChanged(ctx, tx, o.OrderUID)
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// messageAt возвращает исходное сообщение i-го заказа пакета или nil, если сообщения не переданы.
+func messageAt(messages []*order.RawMessage, i int) *order.RawMessage {
+	if messages == nil {
+		return nil
+	}
+
+	return messages[i]
+}
+
 const createPaymentQuery = `
 insert into payments
     (transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
@@ -182,10 +499,15 @@ insert into deliveries
 	(name, phone, zip, city, address, region, email)
 	values ($1, $2, $3, $4, $5, $6, $7) returning id`
 
-func (*PostgresRepository) createDelivery(ctx context.Context, tx pgx.Tx, delivery *order.Delivery) error {
-	err := tx.QueryRow(
+func (r *PostgresRepository) createDelivery(ctx context.Context, tx pgx.Tx, delivery *order.Delivery) error {
+	sealed, err := sealDelivery(r.sealer, delivery)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
 		ctx, createDeliveryQuery,
-		delivery.Name, delivery.Phone, delivery.Zip, delivery.City, delivery.Address, delivery.Region, delivery.Email,
+		sealed.Name, sealed.Phone, sealed.Zip, sealed.City, sealed.Address, sealed.Region, sealed.Email,
 	).Scan(&delivery.ID)
 
 	return err
@@ -205,3 +527,114 @@ func (r *PostgresRepository) createItem(ctx context.Context, tx pgx.Tx, item *or
 
 	return err
 }
+
+const createRawMessageQuery = `
+insert into order_messages
+    (order_uid, subject, headers, body, received_at)
+    values ($1, $2, $3, $4, $5)`
+
+// rawMessageBodyAAD - дополнительные данные при шифровании тела исходного сообщения.
+const rawMessageBodyAAD = "order_messages.body"
+
+func (r *PostgresRepository) createRawMessage(ctx context.Context, tx pgx.Tx, uid string, message *order.RawMessage) error {
+	headers := message.Headers
+	if headers == nil {
+		headers = map[string][]string{}
+	}
+
+	// Тело сообщения содержит те же персональные данные, что и доставка.
+	body, err := r.sealer.Seal(string(message.Body), rawMessageBodyAAD)
+	if err != nil {
+		return fmt.Errorf("error encrypting message body: %s", err)
+	}
+
+	_, err = tx.Exec(ctx, createRawMessageQuery, uid, message.Subject, headers, []byte(body), message.ReceivedAt)
+	return err
+}
+
+const getRawMessageQuery = `
+select order_uid, subject, headers, body, received_at
+from order_messages where order_uid = $1`
+
+// GetRawMessage возвращает исходное сообщение, из которого был получен заказ.
+func (r *PostgresRepository) GetRawMessage(ctx context.Context, uid string) (*order.RawMessage, error) {
+	var message *order.RawMessage
+	err := r.read(uid, func(pool *pgxpool.Pool) (err error) {
+		message, err = r.getRawMessageFrom(ctx, pool, uid)
+		return err
+	})
+
+	return message, err
+}
+
+func (r *PostgresRepository) getRawMessageFrom(ctx context.Context, pool *pgxpool.Pool, uid string) (*order.RawMessage, error) {
+	var message order.RawMessage
+	err := pgxscan.Get(ctx, pool, &message, getRawMessageQuery, uid)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, order.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("error fetching raw message from database: %s", err)
+	}
+
+	body, err := r.sealer.Open(string(message.Body), rawMessageBodyAAD)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting message body: %s", err)
+	}
+
+	message.Body = []byte(body)
+	return &message, nil
+}
+
+const notifyOrderChangedQuery = `select pg_notify($1, $2)`
+
+// orderChanged отмечает изменение заказа в транзакции tx и вызывается каждым изменяющим заказ методом перед
+// фиксацией транзакции. Уведомление об изменении ставится в очередь, и Postgres доставит его слушателям только
+// после успешной фиксации. Запись запоминается в наборе реплик ещё до фиксации, чтобы сразу после неё заказ
+// не читался с отстающей реплики; если транзакция будет отменена, заказ лишь некоторое время читается с основного
+// сервера.
+func (r *PostgresRepository) orderChanged(ctx context.Context, tx pgx.Tx, uid string) error {
+	_, err := tx.Exec(ctx, notifyOrderChangedQuery, OrderChangesChannel, uid)
+	if err != nil {
+		return fmt.Errorf("error sending order change notification: %s", err)
+	}
+
+	r.replicas.wrote(uid)
+
+	return nil
+}
+
+const changedOrderUIDsQuery = `select order_uid from orders where updated_at >= $1`
+
+// ChangedOrderUIDs возвращает UID заказов, изменённых начиная с момента since.
+func (r *PostgresRepository) ChangedOrderUIDs(ctx context.Context, since time.Time) ([]string, error) {
+	var uids []string
+	err := pgxscan.Select(ctx, r.pool, &uids, changedOrderUIDsQuery, since)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching changed orders from database: %s", err)
+	}
+
+	return uids, nil
+}
+
+// inTransaction выполняет fn в транзакции, которая фиксируется, если fn не вернула ошибку.
+func (r *PostgresRepository) inTransaction(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("error committing transaction: %s", err)
+	}
+
+	return nil
+}
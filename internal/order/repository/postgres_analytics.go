@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/money"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// salesByOrderQuery и salesByBrandQuery - строки, из которых складываются показатели продаж: по одной на заказ
+// или на каждый бренд в заказе.
+const (
+	salesByOrderQuery = `
+select o.order_uid, o.date_created, o.delivery_service, d.region, p.currency,
+       p.amount as revenue, p.delivery_cost, null::varchar as brand
+from orders o
+         join payments p on p.transaction = o.transaction
+         left join deliveries d on d.id = o.delivery_id
+where o.date_created >= $1 and o.date_created < $2`
+
+	salesByBrandQuery = `
+select o.order_uid, o.date_created, o.delivery_service, d.region, p.currency,
+       sum(i.total_price) as revenue, p.delivery_cost, i.brand
+from items i
+         join orders o on o.order_uid = i.order_uid
+         join payments p on p.transaction = o.transaction
+         left join deliveries d on d.id = o.delivery_id
+where o.date_created >= $1 and o.date_created < $2
+group by o.order_uid, o.date_created, o.delivery_service, d.region, p.currency, p.delivery_cost, i.brand`
+)
+
+// Суммы считаются в сотых долях, так как итоги могут не поместиться в numeric(10, 2), а значит и в money.Amount
+// при чтении numeric.
+const salesQuery = `
+with sales as (%s)
+select %s as period,
+       %s as brand,
+       %s as delivery_service,
+       %s as region,
+       currency,
+       count(*)                                     as order_count,
+       (sum(revenue) * 100)::bigint                 as revenue,
+       round(sum(revenue) * 100 / count(*))::bigint as average_basket,
+       (sum(delivery_cost) * 100)::bigint           as delivery_cost
+from sales
+group by 1, 2, 3, 4, 5
+order by 1, 2, 3, 4, 5`
+
+type salesRow struct {
+	Period          *time.Time `db:"period"`
+	Brand           *string    `db:"brand"`
+	DeliveryService *string    `db:"delivery_service"`
+	Region          *string    `db:"region"`
+	Currency        string     `db:"currency"`
+
+	OrderCount    int64 `db:"order_count"`
+	Revenue       int64 `db:"revenue"`
+	AverageBasket int64 `db:"average_basket"`
+	DeliveryCost  int64 `db:"delivery_cost"`
+}
+
+// Sales рассчитывает показатели продаж за период с группировкой по указанным признакам.
+func (r *PostgresRepository) Sales(ctx context.Context, query order.SalesQuery) ([]*order.SalesRow, error) {
+	err := query.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	sql := fmt.Sprintf(
+		salesQuery,
+		salesSource(query),
+		salesPeriod(query),
+		dimensionColumn(query, order.DimensionBrand, "brand"),
+		dimensionColumn(query, order.DimensionDeliveryService, "delivery_service"),
+		dimensionColumn(query, order.DimensionRegion, "region"),
+	)
+
+	var rows []*salesRow
+	err = r.read("", func(pool *pgxpool.Pool) error {
+		rows = nil
+		return pgxscan.Select(ctx, pool, &rows, sql, query.From, query.To)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error calculating sales: %s", err)
+	}
+
+	result := make([]*order.SalesRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, &order.SalesRow{
+			Period:          row.Period,
+			Brand:           row.Brand,
+			DeliveryService: row.DeliveryService,
+			Region:          row.Region,
+			Currency:        row.Currency,
+			OrderCount:      row.OrderCount,
+			Revenue:         money.Amount(row.Revenue),
+			AverageBasket:   money.Amount(row.AverageBasket),
+			DeliveryCost:    money.Amount(row.DeliveryCost),
+		})
+	}
+
+	return result, nil
+}
+
+func salesSource(query order.SalesQuery) string {
+	if query.Has(order.DimensionBrand) {
+		return salesByBrandQuery
+	}
+
+	return salesByOrderQuery
+}
+
+func salesPeriod(query order.SalesQuery) string {
+	switch {
+	case query.Has(order.DimensionDay):
+		return "date_trunc('day', date_created)"
+	case query.Has(order.DimensionWeek):
+		return "date_trunc('week', date_created)"
+	default:
+		return "null::timestamp"
+	}
+}
+
+func dimensionColumn(query order.SalesQuery, dimension order.Dimension, column string) string {
+	if query.Has(dimension) {
+		return column
+	}
+
+	return "null::varchar"
+}
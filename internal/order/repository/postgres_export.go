@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"wb-l0/internal/order"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+)
+
+// exportBatchSize - количество заказов, получаемых из курсора выгрузки за один запрос.
+const exportBatchSize = 500
+
+const (
+	declareExportCursorQuery = `
+declare export_orders no scroll cursor for
+select` + orderColumns + `,` + orderItemsAggregate + orderJoins + `
+where true%s
+order by o.date_created, o.order_uid`
+
+	fetchExportCursorQuery = `fetch forward %d from export_orders`
+)
+
+// ExportOrders выгружает заказы через курсор на стороне сервера, поэтому в памяти одновременно находится не
+// больше exportBatchSize заказов. Выгрузка читается с реплики, если она доступна, из одного снимка базы данных.
+// При ошибке выгрузка не повторяется на ведущем сервере, так как часть заказов уже могла быть передана fn.
+func (r *PostgresRepository) ExportOrders(
+	ctx context.Context, filter order.Filter, fn func(o *order.Order) error,
+) error {
+	var where strings.Builder
+	conditions, args := filterConditions(filter, nil)
+	for _, condition := range conditions {
+		where.WriteString("\n  and " + condition)
+	}
+
+	pool := r.pool
+	if replica := r.replicas.pick(""); replica != nil {
+		pool = replica.pool
+	}
+
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(declareExportCursorQuery, where.String()), args...)
+	if err != nil {
+		return fmt.Errorf("error declaring export cursor: %s", err)
+	}
+
+	fetch := fmt.Sprintf(fetchExportCursorQuery, exportBatchSize)
+	for {
+		var rows []*aggregatedOrderRow
+		err = pgxscan.Select(ctx, tx, &rows, fetch)
+		if err != nil {
+			return fmt.Errorf("error fetching orders from database: %s", err)
+		}
+
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			o := row.order()
+			err = openDelivery(r.sealer, o.Delivery)
+			if err != nil {
+				return err
+			}
+
+			err = fn(o)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"wb-l0/internal/order"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+)
+
+var errEncryptionDisabled = errors.New("encryption keyring is not configured")
+
+const selectDeliveriesForReencryptionQuery = `
+select id, name, phone, address, email from deliveries
+where id > $1
+order by id
+limit $2
+for update`
+
+const updateDeliveryPIIQuery = `
+update deliveries set name = $2, phone = $3, address = $4, email = $5
+where id = $1`
+
+// rebuildSearchDocumentsWithoutNameQuery пересоздаёт поисковые документы заказов с доставками $1 так же, как
+// createSearchDocument при включённом шифровании: без имени получателя.
+const rebuildSearchDocumentsWithoutNameQuery = `
+update order_search s
+set document = setweight(to_tsvector('simple', coalesce((select string_agg(concat_ws(' ', i.name, i.brand), ' ')
+                                                        from items i
+                                                        where i.order_uid = o.order_uid), '')), 'A') ||
+               setweight(to_tsvector('simple', coalesce(d.city, '')), 'B')
+from orders o
+         join deliveries d on d.id = o.delivery_id
+where s.order_uid = o.order_uid
+  and d.id = any ($1)`
+
+// ReencryptDeliveries перешифровывает активным ключом персональные данные всех доставок, которые не зашифрованы
+// или зашифрованы другим ключом. Доставки обрабатываются транзакциями по batchSize штук. Поисковые документы
+// заказов с этими доставками пересоздаются без имени получателя, которое индексировалось, пока шифрование
+// было выключено. Возвращает количество перешифрованных доставок.
+func (r *PostgresRepository) ReencryptDeliveries(ctx context.Context, batchSize int) (int, error) {
+	if r.sealer == nil {
+		return 0, errEncryptionDisabled
+	}
+
+	var lastID int64
+	total := 0
+	for {
+		var deliveries []*order.Delivery
+		var updated []int64
+
+		err := r.inTransaction(ctx, func(tx pgx.Tx) error {
+			updated = nil
+			err := pgxscan.Select(ctx, tx, &deliveries, selectDeliveriesForReencryptionQuery, lastID, batchSize)
+			if err != nil {
+				return fmt.Errorf("error selecting deliveries: %s", err)
+			}
+
+			for _, delivery := range deliveries {
+				if !r.deliveryNeedsRotation(delivery) {
+					continue
+				}
+
+				err := openDelivery(r.sealer, delivery)
+				if err != nil {
+					return fmt.Errorf("delivery %d: %s", delivery.ID, err)
+				}
+
+				sealed, err := sealDelivery(r.sealer, delivery)
+				if err != nil {
+					return fmt.Errorf("delivery %d: %s", delivery.ID, err)
+				}
+
+				_, err = tx.Exec(ctx, updateDeliveryPIIQuery, sealed.ID, sealed.Name, sealed.Phone, sealed.Address, sealed.Email)
+				if err != nil {
+					return fmt.Errorf("error updating delivery %d: %s", delivery.ID, err)
+				}
+
+				updated = append(updated, delivery.ID)
+			}
+
+			if len(updated) == 0 {
+				return nil
+			}
+
+			_, err = tx.Exec(ctx, rebuildSearchDocumentsWithoutNameQuery, updated)
+			if err != nil {
+				return fmt.Errorf("error rebuilding search documents: %s", err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += len(updated)
+		if len(deliveries) < batchSize {
+			return total, nil
+		}
+
+		lastID = deliveries[len(deliveries)-1].ID
+	}
+}
+
+func (r *PostgresRepository) deliveryNeedsRotation(delivery *order.Delivery) bool {
+	for _, value := range deliveryPII(delivery) {
+		if r.sealer.NeedsRotation(*value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+const selectRawMessagesForReencryptionQuery = `
+select order_uid, body from order_messages
+where order_uid > $1
+order by order_uid
+limit $2
+for update`
+
+// ReencryptRawMessages перешифровывает активным ключом тела исходных сообщений, которые не зашифрованы
+// или зашифрованы другим ключом. Возвращает количество перешифрованных сообщений.
+func (r *PostgresRepository) ReencryptRawMessages(ctx context.Context, batchSize int) (int, error) {
+	if r.sealer == nil {
+		return 0, errEncryptionDisabled
+	}
+
+	lastUID := ""
+	total := 0
+	for {
+		var messages []*order.RawMessage
+		updated := 0
+
+		err := r.inTransaction(ctx, func(tx pgx.Tx) error {
+			err := pgxscan.Select(ctx, tx, &messages, selectRawMessagesForReencryptionQuery, lastUID, batchSize)
+			if err != nil {
+				return fmt.Errorf("error selecting raw messages: %s", err)
+			}
+
+			for _, message := range messages {
+				if !r.sealer.NeedsRotation(string(message.Body)) {
+					continue
+				}
+
+				body, err := r.sealer.Open(string(message.Body), rawMessageBodyAAD)
+				if err != nil {
+					return fmt.Errorf("raw message %s: %s", message.OrderUID, err)
+				}
+
+				sealed, err := r.sealer.Seal(body, rawMessageBodyAAD)
+				if err != nil {
+					return fmt.Errorf("raw message %s: %s", message.OrderUID, err)
+				}
+
+				_, err = tx.Exec(ctx, "update order_messages set body = $2 where order_uid = $1", message.OrderUID, []byte(sealed))
+				if err != nil {
+					return fmt.Errorf("error updating raw message %s: %s", message.OrderUID, err)
+				}
+
+				updated++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += updated
+		if len(messages) < batchSize {
+			return total, nil
+		}
+
+		lastUID = messages[len(messages)-1].OrderUID
+	}
+}
+
+const selectArchivedOrdersForReencryptionQuery = `
+select order_uid, document from orders_archive
+where order_uid > $1
+order by order_uid
+limit $2
+for update`
+
+type archivedOrderRow struct {
+	OrderUID string `db:"order_uid"`
+	Document []byte `db:"document"`
+}
+
+// ReencryptArchive перешифровывает активным ключом персональные данные доставки в заказах из orders_archive,
+// которые не зашифрованы или зашифрованы другим ключом. Остальные поля документов не меняются.
+// Возвращает количество перешифрованных заказов.
+func (r *PostgresRepository) ReencryptArchive(ctx context.Context, batchSize int) (int, error) {
+	if r.sealer == nil {
+		return 0, errEncryptionDisabled
+	}
+
+	lastUID := ""
+	total := 0
+	for {
+		var rows []*archivedOrderRow
+		updated := 0
+
+		err := r.inTransaction(ctx, func(tx pgx.Tx) error {
+			err := pgxscan.Select(ctx, tx, &rows, selectArchivedOrdersForReencryptionQuery, lastUID, batchSize)
+			if err != nil {
+				return fmt.Errorf("error selecting archived orders: %s", err)
+			}
+
+			for _, row := range rows {
+				document, changed, err := r.reencryptArchivedDocument(row.Document)
+				if err != nil {
+					return fmt.Errorf("archived order %s: %s", row.OrderUID, err)
+				}
+
+				if !changed {
+					continue
+				}
+
+				_, err = tx.Exec(ctx, "update orders_archive set document = $2 where order_uid = $1", row.OrderUID, document)
+				if err != nil {
+					return fmt.Errorf("error updating archived order %s: %s", row.OrderUID, err)
+				}
+
+				updated++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += updated
+		if len(rows) < batchSize {
+			return total, nil
+		}
+
+		lastUID = rows[len(rows)-1].OrderUID
+	}
+}
+
+// reencryptArchivedDocument перешифровывает доставку в документе архивного заказа. Документ разбирается
+// только до полей верхнего уровня, чтобы не потерять при перезаписи поля, которых нет в order.Order.
+func (r *PostgresRepository) reencryptArchivedDocument(document []byte) ([]byte, bool, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(document, &fields)
+	if err != nil {
+		return nil, false, fmt.Errorf("error parsing document: %s", err)
+	}
+
+	raw, ok := fields["delivery"]
+	if !ok {
+		return nil, false, nil
+	}
+
+	var delivery *order.Delivery
+	err = json.Unmarshal(raw, &delivery)
+	if err != nil {
+		return nil, false, fmt.Errorf("error parsing delivery: %s", err)
+	}
+
+	if delivery == nil || !r.deliveryNeedsRotation(delivery) {
+		return nil, false, nil
+	}
+
+	err = openDelivery(r.sealer, delivery)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sealed, err := sealDelivery(r.sealer, delivery)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fields["delivery"], err = json.Marshal(sealed)
+	if err != nil {
+		return nil, false, fmt.Errorf("error marshalling delivery: %s", err)
+	}
+
+	document, err = json.Marshal(fields)
+	if err != nil {
+		return nil, false, fmt.Errorf("error marshalling document: %s", err)
+	}
+
+	return document, true, nil
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/conformance"
+	"wb-l0/internal/pgtest"
+)
+
+// archivedDocument возвращает документ архивного заказа с доставкой, зашифрованной sealer, и полем legacy_field,
+// которого нет в order.Order.
+func archivedDocument(t *testing.T, o *order.Order, seal func(*order.Delivery) *order.Delivery) []byte {
+	t.Helper()
+
+	archived := *o
+	if seal != nil {
+		archived.Delivery = seal(o.Delivery)
+	}
+
+	document, err := json.Marshal(&archived)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte(strings.Replace(string(document), "{", `{"legacy_field":"kept",`, 1))
+}
+
+// openArchivedDocument расшифровывает доставку в документе и проверяет, что она зашифрована ключом keyID.
+func openArchivedDocument(t *testing.T, repo *PostgresRepository, document []byte, keyID string) *order.Order {
+	t.Helper()
+
+	var fields map[string]any
+	err := json.Unmarshal(document, &fields)
+	if err != nil {
+		t.Fatalf("error parsing re-encrypted document: %s", err)
+	}
+
+	if fields["legacy_field"] != "kept" {
+		t.Error("unknown document field is lost")
+	}
+
+	var o order.Order
+	err = json.Unmarshal(document, &o)
+	if err != nil {
+		t.Fatalf("error parsing re-encrypted document: %s", err)
+	}
+
+	for field, value := range deliveryPII(o.Delivery) {
+		if !strings.HasPrefix(*value, "enc:v1:"+keyID+":") {
+			t.Errorf("%s is not encrypted with key %s: %q", field, keyID, *value)
+		}
+	}
+
+	err = openDelivery(repo.sealer, o.Delivery)
+	if err != nil {
+		t.Fatalf("error decrypting re-encrypted delivery: %s", err)
+	}
+
+	return &o
+}
+
+func TestReencryptArchivedDocument(t *testing.T) {
+	keys := newTestKeys("old", "new")
+	oldSealer := newTestSealer(t, "old", map[string][]byte{"old": keys["old"]})
+	repo := &PostgresRepository{sealer: newTestSealer(t, "new", keys)}
+	o := conformance.SampleOrder("archived", 1)
+
+	tests := []struct {
+		name string
+		seal func(*order.Delivery) *order.Delivery
+	}{
+		{name: "sealed with old key", seal: func(delivery *order.Delivery) *order.Delivery {
+			sealed, err := sealDelivery(oldSealer, delivery)
+			if err != nil {
+				t.Fatalf("error sealing delivery: %s", err)
+			}
+			return sealed
+		}},
+		{name: "archived before encryption"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document, changed, err := repo.reencryptArchivedDocument(archivedDocument(t, o, test.seal))
+			if err != nil {
+				t.Fatalf("error re-encrypting document: %s", err)
+			}
+
+			if !changed {
+				t.Fatal("document is not re-encrypted")
+			}
+
+			assertSameOrder(t, o, openArchivedDocument(t, repo, document, "new"))
+
+			_, changed, err = repo.reencryptArchivedDocument(document)
+			if err != nil || changed {
+				t.Errorf("document sealed with active key is re-encrypted again: changed = %t, error = %v", changed, err)
+			}
+		})
+	}
+}
+
+func TestReencryptArchivedDocumentWithoutDelivery(t *testing.T) {
+	repo := &PostgresRepository{sealer: newTestSealer(t, "new", nil)}
+	for _, document := range []string{`{"order_uid":"a"}`, `{"order_uid":"a","delivery":null}`} {
+		_, changed, err := repo.reencryptArchivedDocument([]byte(document))
+		if err != nil || changed {
+			t.Errorf("%s: changed = %t, error = %v, want unchanged document", document, changed, err)
+		}
+	}
+}
+
+func TestReencryptArchivedDocumentUnknownKey(t *testing.T) {
+	o := conformance.SampleOrder("archived", 1)
+	retired := newTestSealer(t, "retired", nil)
+	document := archivedDocument(t, o, func(delivery *order.Delivery) *order.Delivery {
+		sealed, _ := sealDelivery(retired, delivery)
+		return sealed
+	})
+
+	repo := &PostgresRepository{sealer: newTestSealer(t, "new", nil)}
+	_, _, err := repo.reencryptArchivedDocument(document)
+	if err == nil {
+		t.Error("document sealed with a key missing from keyring is re-encrypted without error")
+	}
+}
+
+func TestReencryptDeliveriesRemovesNameFromSearch(t *testing.T) {
+	ctx := context.Background()
+	pool, err := NewPostgresPool(ctx, pgtest.NewDatabase(t).URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	// Заказ сохранён до включения шифрования, поэтому имя получателя проиндексировано.
+	o := conformance.SampleOrder("search-pii", 1)
+	err = NewPostgresRepository(pool, ReadModeAggregated, nil).CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatalf("error creating order: %s", err)
+	}
+
+	countMatches := func(word string) int {
+		t.Helper()
+
+		var count int
+		err := pool.QueryRow(
+			ctx, `select count(*) from order_search where document @@ to_tsquery('simple', $1)`, word,
+		).Scan(&count)
+		if err != nil {
+			t.Fatalf("error searching for %s: %s", word, err)
+		}
+
+		return count
+	}
+
+	if countMatches("testov") != 1 {
+		t.Fatal("recipient name is not indexed without encryption")
+	}
+
+	repo := NewPostgresRepository(pool, ReadModeAggregated, newTestSealer(t, "key-1", nil))
+	reencrypted, err := repo.ReencryptDeliveries(ctx, 10)
+	if err != nil {
+		t.Fatalf("error re-encrypting deliveries: %s", err)
+	}
+
+	if reencrypted != 1 {
+		t.Errorf("got %d re-encrypted deliveries, want 1", reencrypted)
+	}
+
+	if countMatches("testov") != 0 || countMatches("test") != 0 {
+		t.Error("recipient name is left in search index after re-encryption")
+	}
+
+	// Город и товары по-прежнему ищутся.
+	city := strings.ToLower(strings.Fields(o.Delivery.City)[0])
+	brand := strings.ToLower(strings.Fields(o.Items[0].Brand)[0])
+	if countMatches(city) != 1 || countMatches(brand) != 1 {
+		t.Errorf("order is not found by city %q or brand %q after re-encryption", city, brand)
+	}
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wb-l0/internal/config"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replicaLagQuery возвращает отставание реплики в секундах. На ведущем сервере pg_last_xact_replay_timestamp()
+// возвращает NULL, такое отставание считается нулевым.
+const replicaLagQuery = `select coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0)::float8`
+
+// replicaSet - набор реплик Postgres, между которыми по кругу распределяется чтение. Реплика используется,
+// только пока она проходит проверки доступности; ошибка чтения с реплики исключает её до следующей проверки.
+//
+// Заказы, сохранённые этим экземпляром сервиса менее window назад, читаются с ведущего сервера, чтобы
+// только что полученный заказ был виден, даже если реплики ещё не успели его получить.
+//
+// Нулевой указатель на replicaSet является корректным пустым набором: всё чтение идёт на ведущий сервер.
+type replicaSet struct {
+	replicas      []*replica
+	next          atomic.Uint64
+	maxLag        time.Duration
+	checkInterval time.Duration
+
+	recent *recentWrites
+}
+
+type replica struct {
+	pool    *pgxpool.Pool
+	host    string
+	healthy atomic.Bool
+}
+
+func newReplicaSetFromConfig(ctx context.Context, cfg config.PostgresConnection) (*replicaSet, error) {
+	if len(cfg.ReplicaURLs) == 0 {
+		return nil, nil
+	}
+
+	set := &replicaSet{
+		maxLag:        cfg.ReplicaMaxLag,
+		checkInterval: cfg.ReplicaCheckInterval,
+		recent:        newRecentWrites(cfg.ReadYourWritesWindow),
+	}
+
+	for _, url := range cfg.ReplicaURLs {
+		poolConfig, err := newPoolConfig(url, cfg.MaxConns)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("error parsing replica url: %s", err)
+		}
+
+		// В отличие от ведущего сервера, недоступность реплики при запуске не считается ошибкой:
+		// она начнёт использоваться после первой успешной проверки.
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			set.close()
+			return nil, fmt.Errorf("error creating replica pool: %s", err)
+		}
+
+		set.replicas = append(set.replicas, &replica{pool: pool, host: poolConfig.ConnConfig.Host})
+	}
+
+	set.checkAll(ctx, time.Second)
+	return set, nil
+}
+
+// pick возвращает реплику, с которой можно прочитать заказ uid, или nil, если читать нужно с ведущего сервера.
+func (s *replicaSet) pick(uid string) *replica {
+	if s == nil || s.recent.contains(uid) {
+		return nil
+	}
+
+	start := s.next.Add(1)
+	for i := range s.replicas {
+		r := s.replicas[(int(start)+i)%len(s.replicas)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// wrote запоминает, что заказ uid только что сохранён.
+func (s *replicaSet) wrote(uid string) {
+	if s == nil {
+		return
+	}
+
+	s.recent.add(uid)
+}
+
+// run периодически проверяет доступность реплик до отмены контекста.
+func (s *replicaSet) run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkAll(ctx, s.checkInterval)
+		}
+	}
+}
+
+func (s *replicaSet) checkAll(ctx context.Context, timeout time.Duration) {
+	for _, r := range s.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := s.check(checkCtx, r)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			r.markUnhealthy(err)
+			continue
+		}
+
+		if !r.healthy.Swap(true) {
+			log.Printf("postgres replica %s is available\n", r.host)
+		}
+	}
+}
+
+func (s *replicaSet) check(ctx context.Context, r *replica) error {
+	var lag float64
+	err := r.pool.QueryRow(ctx, replicaLagQuery).Scan(&lag)
+	if err != nil {
+		return err
+	}
+
+	if s.maxLag > 0 && time.Duration(lag*float64(time.Second)) > s.maxLag {
+		return fmt.Errorf("replication lag of %.1fs exceeds %s", lag, s.maxLag)
+	}
+
+	return nil
+}
+
+func (s *replicaSet) close() {
+	for _, r := range s.replicas {
+		r.pool.Close()
+	}
+}
+
+func (r *replica) markUnhealthy(err error) {
+	// Отмена запроса клиентом не говорит о проблемах с репликой.
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if r.healthy.Swap(false) {
+		log.Printf("postgres replica %s is unavailable, reading from primary: %s\n", r.host, err)
+	}
+}
+
+// recentWrites - UID заказов, сохранённых не более window назад. Записи добавляются в порядке времени,
+// поэтому устаревшие удаляются с начала очереди.
+type recentWrites struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*list.Element
+	queue   *list.List
+}
+
+type recentWrite struct {
+	uid       string
+	expiresAt time.Time
+}
+
+func newRecentWrites(window time.Duration) *recentWrites {
+	return &recentWrites{
+		window:  window,
+		entries: make(map[string]*list.Element),
+		queue:   list.New(),
+	}
+}
+
+func (w *recentWrites) add(uid string) {
+	if w.window <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expire()
+	if element, ok := w.entries[uid]; ok {
+		w.queue.Remove(element)
+	}
+
+	w.entries[uid] = w.queue.PushBack(&recentWrite{uid: uid, expiresAt: time.Now().Add(w.window)})
+}
+
+func (w *recentWrites) contains(uid string) bool {
+	if w.window <= 0 {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expire()
+	_, ok := w.entries[uid]
+	return ok
+}
+
+func (w *recentWrites) expire() {
+	now := time.Now()
+	for element := w.queue.Front(); element != nil; element = w.queue.Front() {
+		entry := element.Value.(*recentWrite)
+		if now.Before(entry.expiresAt) {
+			return
+		}
+
+		w.queue.Remove(element)
+		delete(w.entries, entry.uid)
+	}
+}
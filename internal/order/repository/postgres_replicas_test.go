@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"wb-l0/internal/order"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestPool создаёт пул, который не подключается к серверу, пока через него не выполнен запрос.
+func newTestPool(t *testing.T, host string) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := pgxpool.New(context.Background(), "postgres://"+host+".invalid/orders")
+	if err != nil {
+		t.Fatalf("error creating pool: %s", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// newTestReplicaSet создаёт набор из n доступных реплик без подключения к ним.
+func newTestReplicaSet(t *testing.T, n int, window time.Duration) *replicaSet {
+	t.Helper()
+
+	set := &replicaSet{recent: newRecentWrites(window)}
+	for i := 0; i < n; i++ {
+		host := fmt.Sprintf("replica-%d", i)
+		r := &replica{pool: newTestPool(t, host), host: host}
+		r.healthy.Store(true)
+		set.replicas = append(set.replicas, r)
+	}
+
+	return set
+}
+
+func TestReplicaSetPickRoundRobin(t *testing.T) {
+	set := newTestReplicaSet(t, 3, 0)
+
+	picked := make(map[*replica]int)
+	for i := 0; i < 30; i++ {
+		picked[set.pick("order")]++
+	}
+
+	for _, r := range set.replicas {
+		if picked[r] != 10 {
+			t.Errorf("replica %s is picked %d times out of 30, want 10", r.host, picked[r])
+		}
+	}
+}
+
+func TestReplicaSetSkipsUnhealthy(t *testing.T) {
+	set := newTestReplicaSet(t, 3, 0)
+	set.replicas[1].markUnhealthy(errors.New("connection refused"))
+
+	for i := 0; i < 10; i++ {
+		if r := set.pick("order"); r == nil || r == set.replicas[1] {
+			t.Fatalf("got replica %v, want a healthy one", r)
+		}
+	}
+
+	// Отмена запроса не делает реплику недоступной.
+	set.replicas[0].markUnhealthy(context.Canceled)
+	if !set.replicas[0].healthy.Load() {
+		t.Error("replica is marked unhealthy after canceled request")
+	}
+
+	set.replicas[0].markUnhealthy(errors.New("connection refused"))
+	set.replicas[2].markUnhealthy(errors.New("connection refused"))
+	if r := set.pick("order"); r != nil {
+		t.Errorf("got replica %s when all replicas are unhealthy, want primary", r.host)
+	}
+}
+
+func TestReplicaSetReadYourWrites(t *testing.T) {
+	window := 50 * time.Millisecond
+	set := newTestReplicaSet(t, 2, window)
+
+	set.wrote("written")
+	if r := set.pick("written"); r != nil {
+		t.Errorf("got replica %s for just written order, want primary", r.host)
+	}
+
+	if r := set.pick("other"); r == nil {
+		t.Error("got primary for order that was not written, want replica")
+	}
+
+	time.Sleep(window)
+	if r := set.pick("written"); r == nil {
+		t.Error("got primary after read-your-writes window has passed, want replica")
+	}
+}
+
+func TestRecentWrites(t *testing.T) {
+	disabled := newRecentWrites(0)
+	disabled.add("order")
+	if disabled.contains("order") {
+		t.Error("write is remembered with zero window")
+	}
+
+	window := 200 * time.Millisecond
+	recent := newRecentWrites(window)
+	recent.add("first")
+	time.Sleep(window / 2)
+	recent.add("second")
+	recent.add("first")
+
+	// Повторная запись продлевает окно.
+	time.Sleep(window / 2)
+	if !recent.contains("first") || !recent.contains("second") {
+		t.Error("writes are forgotten before window has passed")
+	}
+
+	time.Sleep(window)
+	if recent.contains("first") || recent.contains("second") || recent.queue.Len() != 0 {
+		t.Error("writes are remembered after window has passed")
+	}
+}
+
+func TestNilReplicaSet(t *testing.T) {
+	var set *replicaSet
+	set.wrote("order")
+	if set.pick("order") != nil {
+		t.Error("empty replica set returns a replica")
+	}
+}
+
+func TestPostgresReadFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		replicaErr  error
+		wantPrimary bool
+		wantHealthy bool
+	}{
+		{"replica succeeds", nil, false, true},
+		{"replica fails", errors.New("connection reset"), true, false},
+		{"order is not on replica yet", order.ErrNotFound, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newTestPool(t, "primary")
+			repo := NewPostgresRepository(primary, ReadModeAggregated, nil)
+			repo.replicas = newTestReplicaSet(t, 1, 0)
+			replica := repo.replicas.replicas[0]
+
+			var pools []*pgxpool.Pool
+			err := repo.read("order", func(pool *pgxpool.Pool) error {
+				pools = append(pools, pool)
+				if pool == replica.pool {
+					return tt.replicaErr
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+
+			if pools[0] != replica.pool {
+				t.Error("read is not sent to replica first")
+			}
+
+			if usedPrimary := pools[len(pools)-1] == primary; usedPrimary != tt.wantPrimary {
+				t.Errorf("read from primary: %t, want %t", usedPrimary, tt.wantPrimary)
+			}
+
+			if replica.healthy.Load() != tt.wantHealthy {
+				t.Errorf("replica healthy: %t, want %t", replica.healthy.Load(), tt.wantHealthy)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+)
+
+// Блокировка skip locked позволяет нескольким экземплярам сервиса удалять заказы параллельно,
+// не мешая друг другу.
+const selectExpiredOrdersQuery = `
+select order_uid from orders
+where date_created < $1
+order by date_created
+limit $2
+for update skip locked`
+
+const archiveOrderQuery = `
+insert into orders_archive (order_uid, date_created, document)
+values ($1, $2, $3)
+on conflict (order_uid) do nothing`
+
+const deleteOrdersQuery = `
+delete from orders where order_uid = any($1)
+returning delivery_id, transaction`
+
+// PurgeOrders удаляет не более limit заказов, созданных раньше before, вместе с их товарами, доставками,
+// платежами, историей состояний и статусов, исходными сообщениями и поисковыми документами. Если archive равен true,
+// перед удалением заказы целиком сохраняются в таблицу orders_archive. Возвращает UID удалённых заказов.
+func (r *PostgresRepository) PurgeOrders(ctx context.Context, before time.Time, limit int, archive bool) ([]string, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var uids []string
+	err = pgxscan.Select(ctx, tx, &uids, selectExpiredOrdersQuery, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting expired orders: %s", err)
+	}
+
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	if archive {
+		err = r.archiveOrders(ctx, tx, uids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, "delete from order_messages where order_uid = any($1)", uids)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting raw messages: %s", err)
+	}
+
+	_, err = tx.Exec(ctx, "delete from order_search where order_uid = any($1)", uids)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting search documents: %s", err)
+	}
+
+	_, err = tx.Exec(ctx, "delete from order_state_transitions where order_uid = any($1)", uids)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting order state history: %s", err)
+	}
+
+	_, err = tx.Exec(ctx, "delete from item_status_history where order_uid = any($1)", uids)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting item status history: %s", err)
+	}
+
+	_, err = tx.Exec(ctx, "delete from items where order_uid = any($1)", uids)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting items: %s", err)
+	}
+
+	var deleted []struct {
+		DeliveryID  int64  `db:"delivery_id"`
+		Transaction string `db:"transaction"`
+	}
+	err = pgxscan.Select(ctx, tx, &deleted, deleteOrdersQuery, uids)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting orders: %s", err)
+	}
+
+	deliveryIDs := make([]int64, 0, len(deleted))
+	transactions := make([]string, 0, len(deleted))
+	for _, row := range deleted {
+		deliveryIDs = append(deliveryIDs, row.DeliveryID)
+		transactions = append(transactions, row.Transaction)
+	}
+
+	_, err = tx.Exec(ctx, "delete from deliveries where id = any($1)", deliveryIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting deliveries: %s", err)
+	}
+
+	_, err = tx.Exec(ctx, "delete from payments where transaction = any($1)", transactions)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting payments: %s", err)
+	}
+
+	for _, uid := range uids {
+		err = r.orderChanged(ctx, tx, uid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error committing transaction: %s", err)
+	}
+
+	return uids, nil
+}
+
+func (r *PostgresRepository) archiveOrders(ctx context.Context, tx pgx.Tx, uids []string) error {
+	for _, uid := range uids {
+		o, err := r.getOrderAggregated(ctx, tx, uid)
+		if err != nil {
+			return fmt.Errorf("error fetching order %s for archiving: %s", uid, err)
+		}
+
+		document, err := json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("error marshalling order %s for archiving: %s", uid, err)
+		}
+
+		_, err = tx.Exec(ctx, archiveOrderQuery, uid, o.DateCreated, document)
+		if err != nil {
+			return fmt.Errorf("error archiving order %s: %s", uid, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"wb-l0/internal/order"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const createSearchDocumentQuery = `
+insert into order_search (order_uid, document)
+values ($1, setweight(to_tsvector('simple', $2), 'A') || setweight(to_tsvector('simple', $3), 'B'))`
+
+// createSearchDocument сохраняет поисковый документ заказа. Имя получателя индексируется, только если персональные
+// данные не шифруются: иначе оно оказалось бы в базе данных в открытом виде.
+func (r *PostgresRepository) createSearchDocument(ctx context.Context, tx pgx.Tx, o *order.Order) error {
+	items := make([]string, 0, 2*len(o.Items))
+	for _, item := range o.Items {
+		items = append(items, item.Name, item.Brand)
+	}
+
+	var delivery []string
+	if o.Delivery != nil {
+		if r.sealer == nil {
+			delivery = append(delivery, o.Delivery.Name)
+		}
+		delivery = append(delivery, o.Delivery.City)
+	}
+
+	_, err := tx.Exec(
+		ctx, createSearchDocumentQuery,
+		o.OrderUID, strings.Join(items, " "), strings.Join(delivery, " "),
+	)
+	return err
+}
+
+const searchColumns = `
+select o.order_uid,
+       o.track_number,
+       o.customer_id,
+       o.delivery_service,
+       o.date_created,
+       o.state,
+       p.currency,
+       p.amount,
+       (select count(*) from items i where i.order_uid = o.order_uid) as item_count,`
+
+// SearchOrders ищет заказы по тексту запроса (в синтаксисе websearch_to_tsquery: слова, "фразы", -исключения, or)
+// и фильтру. Без текста запроса отбор можно продолжить с позиции query.After.
+func (r *PostgresRepository) SearchOrders(ctx context.Context, query order.SearchQuery) ([]*order.Summary, error) {
+	if query.After != nil && query.Text != "" {
+		return nil, order.ErrCursorWithText
+	}
+
+	var sql strings.Builder
+	var args []any
+
+	sql.WriteString(searchColumns)
+	if query.Text != "" {
+		args = append(args, query.Text)
+		sql.WriteString(`
+       ts_rank(s.document, q.query) as rank
+from order_search s
+         cross join websearch_to_tsquery('simple', $1) as q(query)
+         join orders o on o.order_uid = s.order_uid
+         join payments p on p.transaction = o.transaction
+where s.document @@ q.query`)
+	} else {
+		sql.WriteString(`
+       0::real as rank
+from orders o
+         join payments p on p.transaction = o.transaction
+where true`)
+	}
+
+	conditions, args := filterConditions(query.Filter, args)
+	for _, condition := range conditions {
+		sql.WriteString("\n  and " + condition)
+	}
+
+	if query.After != nil {
+		args = append(args, query.After.DateCreated, query.After.OrderUID)
+		sql.WriteString(fmt.Sprintf("\n  and (o.date_created, o.order_uid) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, query.PageSize())
+	sql.WriteString(fmt.Sprintf("\norder by rank desc, o.date_created desc, o.order_uid desc\nlimit $%d", len(args)))
+
+	var summaries []*order.Summary
+	err := r.read("", func(pool *pgxpool.Pool) error {
+		summaries = nil
+		return pgxscan.Select(ctx, pool, &summaries, sql.String(), args...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching orders: %s", err)
+	}
+
+	return summaries, nil
+}
+
+// filterConditions возвращает условия отбора заказов (таблица orders с псевдонимом o) по фильтру, дописывая
+// их параметры к args.
+func filterConditions(filter order.Filter, args []any) ([]string, []any) {
+	var conditions []string
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.CustomerID != "" {
+		add("o.customer_id = $%d", filter.CustomerID)
+	}
+
+	if filter.DeliveryService != "" {
+		add("o.delivery_service = $%d", filter.DeliveryService)
+	}
+
+	if filter.State != "" {
+		add("o.state = $%d", filter.State)
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		add("o.date_created >= $%d", filter.CreatedFrom)
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		add("o.date_created < $%d", filter.CreatedTo)
+	}
+
+	return conditions, args
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/conformance"
+	"wb-l0/internal/pgtest"
+)
+
+func TestPostgresListOrdersPages(t *testing.T) {
+	ctx := context.Background()
+	pool, err := NewPostgresPool(ctx, pgtest.NewDatabase(t).URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	repo := NewPostgresRepository(pool, ReadModeAggregated, nil)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// У заказов b и c одинаковая дата создания: их порядок определяется order_uid.
+	for i, uid := range []string{"a", "b", "c", "d"} {
+		o := conformance.SampleOrder("page-"+uid, int64(i+1))
+		o.CustomerID = "paging"
+		o.DateCreated = created
+		switch uid {
+		case "a":
+			o.DateCreated = created.Add(-time.Hour)
+		case "d":
+			o.DateCreated = created.Add(time.Hour)
+		}
+
+		err = repo.CreateOrder(ctx, o)
+		if err != nil {
+			t.Fatalf("error creating order %s: %s", uid, err)
+		}
+	}
+
+	var got []string
+	query := order.SearchQuery{Filter: order.Filter{CustomerID: "paging"}, Limit: 2}
+	for pages := 0; pages < 5; pages++ {
+		summaries, err := repo.SearchOrders(ctx, query)
+		if err != nil {
+			t.Fatalf("error listing orders: %s", err)
+		}
+
+		for _, summary := range summaries {
+			got = append(got, summary.OrderUID)
+		}
+
+		if len(summaries) < query.PageSize() {
+			break
+		}
+		query.After = order.CursorAfter(summaries[len(summaries)-1])
+	}
+
+	want := []string{"page-d", "page-c", "page-b", "page-a"}
+	if len(got) != len(want) {
+		t.Fatalf("got orders %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got orders %v, want %v", got, want)
+		}
+	}
+
+	_, err = repo.SearchOrders(ctx, order.SearchQuery{Text: "nike", After: query.After})
+	if err != order.ErrCursorWithText {
+		t.Errorf("got error %v, want %v", err, order.ErrCursorWithText)
+	}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"wb-l0/internal/order"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	createStateTransitionQuery = `
+insert into order_state_transitions (order_uid, from_state, to_state, changed_at)
+values ($1, $2, $3, $4)`
+
+	lockOrderStateQuery = `select state, version from orders where order_uid = $1 for update`
+
+	updateOrderStateQuery = `
+update orders set state = $2, state_changed_at = $3, updated_at = now(), version = version + 1
+where order_uid = $1
+returning version`
+
+	getStateTransitionsQuery = `
+select from_state, to_state, changed_at
+from order_state_transitions
+where order_uid = $1
+order by changed_at, id`
+)
+
+func (*PostgresRepository) createStateTransition(
+	ctx context.Context, tx pgx.Tx, uid string, transition *order.StateTransition,
+) error {
+	_, err := tx.Exec(ctx, createStateTransitionQuery, uid, transition.From, transition.To, transition.ChangedAt)
+	return err
+}
+
+// ChangeOrderState переводит заказ в новое состояние, проверяя версию заказа и допустимость перехода,
+// и записывает переход в историю. Заказ блокируется на время смены состояния, поэтому одновременные изменения
+// выполняются по очереди.
+func (r *PostgresRepository) ChangeOrderState(
+	ctx context.Context, change order.StateChange,
+) (*order.StateTransition, int64, error) {
+	uid := change.OrderUID
+	var transition *order.StateTransition
+	var version int64
+	err := r.inTransaction(ctx, func(tx pgx.Tx) error {
+		var o order.Order
+		err := tx.QueryRow(ctx, lockOrderStateQuery, uid).Scan(&o.State, &o.Version)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return order.ErrNotFound
+			}
+
+			return fmt.Errorf("error fetching order state: %s", err)
+		}
+
+		err = order.CheckVersion(change.Version, o.Version)
+		if err != nil {
+			return err
+		}
+
+		transition, err = o.TransitionTo(change.State, time.Now())
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(ctx, updateOrderStateQuery, uid, o.State, o.StateChangedAt).Scan(&version)
+		if err != nil {
+			return fmt.Errorf("error updating order state: %s", err)
+		}
+
+		err = r.createStateTransition(ctx, tx, uid, transition)
+		if err != nil {
+			return fmt.Errorf("error saving order state history: %s", err)
+		}
+
+		return r.orderChanged(ctx, tx, uid)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return transition, version, nil
+}
+
+// GetStateTransitions возвращает историю смены состояний заказа.
+func (r *PostgresRepository) GetStateTransitions(ctx context.Context, uid string) ([]*order.StateTransition, error) {
+	var transitions []*order.StateTransition
+	err := r.read(uid, func(pool *pgxpool.Pool) error {
+		transitions = nil
+		err := pgxscan.Select(ctx, pool, &transitions, getStateTransitionsQuery, uid)
+		if err != nil {
+			return err
+		}
+
+		if len(transitions) == 0 {
+			return order.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, order.ErrNotFound) {
+		return nil, fmt.Errorf("error fetching order state history: %s", err)
+	}
+
+	return transitions, err
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"wb-l0/internal/order"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	createItemStatusRecordQuery = `
+insert into item_status_history (chrt_id, order_uid, status, previous_status)
+values ($1, $2, $3, $4)`
+
+	lockOrderVersionQuery = `select version from orders where order_uid = $1 for update`
+
+	lockItemStatusQuery = `select status from items where chrt_id = $1 and order_uid = $2 for update`
+
+	updateItemStatusQuery = `update items set status = $2 where chrt_id = $1`
+
+	touchOrderQuery = `update orders set updated_at = now(), version = version + 1 where order_uid = $1 returning version`
+
+	getItemStatusHistoryQuery = `
+select status, previous_status, changed_at
+from item_status_history
+where chrt_id = $1 and order_uid = $2
+order by changed_at, id`
+)
+
+// createItemStatusRecord записывает в историю статус, с которым товар получен в составе заказа.
+func (*PostgresRepository) createItemStatusRecord(ctx context.Context, tx pgx.Tx, item *order.Item) error {
+	_, err := tx.Exec(ctx, createItemStatusRecordQuery, item.ChrtID, item.OrderUID, item.Status, nil)
+	return err
+}
+
+// ApplyItemStatus меняет статус товара и записывает изменение в историю. Заказ считается изменённым, чтобы
+// его копии были сброшены из кэшей, а его версия увеличивается. Заказ блокируется на время изменения, поэтому
+// одновременные изменения выполняются по очереди.
+func (r *PostgresRepository) ApplyItemStatus(ctx context.Context, change order.ItemStatusChange) (int64, error) {
+	var version int64
+	err := r.inTransaction(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, lockOrderVersionQuery, change.OrderUID).Scan(&version)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return order.ErrNotFound
+			}
+
+			return fmt.Errorf("error fetching order version: %s", err)
+		}
+
+		err = order.CheckVersion(change.Version, version)
+		if err != nil {
+			return err
+		}
+
+		var current int
+		err = tx.QueryRow(ctx, lockItemStatusQuery, change.ChrtID, change.OrderUID).Scan(&current)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return order.ErrNotFound
+			}
+
+			return fmt.Errorf("error fetching item status: %s", err)
+		}
+
+		if current == change.Status {
+			return nil
+		}
+
+		_, err = tx.Exec(ctx, updateItemStatusQuery, change.ChrtID, change.Status)
+		if err != nil {
+			return fmt.Errorf("error updating item status: %s", err)
+		}
+
+		_, err = tx.Exec(ctx, createItemStatusRecordQuery, change.ChrtID, change.OrderUID, change.Status, current)
+		if err != nil {
+			return fmt.Errorf("error saving item status history: %s", err)
+		}
+
+		err = tx.QueryRow(ctx, touchOrderQuery, change.OrderUID).Scan(&version)
+		if err != nil {
+			return fmt.Errorf("error updating order: %s", err)
+		}
+
+		return r.orderChanged(ctx, tx, change.OrderUID)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// GetItemStatusHistory возвращает историю статусов товара. Если товара нет в заказе, возвращается order.ErrNotFound.
+func (r *PostgresRepository) GetItemStatusHistory(
+	ctx context.Context, uid string, chrtID int64,
+) ([]*order.ItemStatusRecord, error) {
+	var history []*order.ItemStatusRecord
+	err := r.read(uid, func(pool *pgxpool.Pool) error {
+		history = nil
+		err := pgxscan.Select(ctx, pool, &history, getItemStatusHistoryQuery, chrtID, uid)
+		if err != nil {
+			return err
+		}
+
+		if len(history) == 0 {
+			return order.ErrNotFound
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, order.ErrNotFound) {
+		return nil, fmt.Errorf("error fetching item status history: %s", err)
+	}
+
+	return history, err
+}
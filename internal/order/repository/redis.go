@@ -2,46 +2,83 @@ package repository
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"wb-l0/internal/config"
 	"wb-l0/internal/order"
+	"wb-l0/pkg/envelope"
 
 	"github.com/redis/go-redis/v9"
 )
 
 // RedisRepository - обёртка над клиентом go-redis, отвечающая за сериализацию/десериализацию данных,
-// позволяющая сохранять и получать данные из Redis.
+// позволяющая сохранять и получать данные из Redis. Используется как общий для всех экземпляров сервиса
+// кэш второго уровня.
 //
-// Был реализован изначально из-за невнимательности при чтении задания. :)
-// Сейчас не используется, оставил просто на память.
+// Заказы хранятся под ключами вида <prefix><order_uid> с ограниченным временем жизни. Персональные данные
+// доставки шифруются перед сохранением, если задан sealer.
+//
+// Недоступность Redis не должна ломать работу сервиса, поэтому после ошибки Redis на некоторое время
+// считается недоступным: чтение в это время ведёт себя как промах кэша, а запись пропускается.
 type RedisRepository struct {
-	client *redis.Client
+	client   *redis.Client
+	prefix   string
+	ttl      time.Duration
+	cooldown time.Duration
+	sealer   *envelope.Sealer
+
+	// unavailableUntil - момент времени (в наносекундах Unix), до которого Redis не используется.
+	unavailableUntil atomic.Int64
 }
 
-func NewRedisRepository(client *redis.Client) *RedisRepository {
+func NewRedisRepository(
+	client *redis.Client, prefix string, ttl time.Duration, cooldown time.Duration, sealer *envelope.Sealer,
+) *RedisRepository {
 	return &RedisRepository{
-		client: client,
+		client:   client,
+		prefix:   prefix,
+		ttl:      ttl,
+		cooldown: cooldown,
+		sealer:   sealer,
 	}
 }
 
-func NewRedisRepositoryFromConfig(cfg config.RedisConnection) *RedisRepository {
-	client := redis.NewClient(&redis.Options{
-		Addr: cfg.Address,
-	})
+func NewRedisRepositoryFromConfig(cfg config.RedisConnection, sealer *envelope.Sealer) *RedisRepository {
+	options := &redis.Options{
+		Addr:         cfg.Address,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	if cfg.TLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
 
-	return NewRedisRepository(client)
+	return NewRedisRepository(redis.NewClient(options), cfg.KeyPrefix, cfg.TTL, cfg.FailureCooldown, sealer)
 }
 
 func (r *RedisRepository) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
-	serializedOrder, err := r.client.Get(ctx, uid).Result()
+	if !r.available() {
+		return nil, order.ErrNotFound
+	}
+
+	serializedOrder, err := r.client.Get(ctx, r.key(uid)).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, order.ErrNotFound
 		}
 
+		r.markUnavailable(err)
 		return nil, fmt.Errorf("error fetching cached order from redis: %s", err)
 	}
 
@@ -51,19 +88,132 @@ func (r *RedisRepository) GetOrder(ctx context.Context, uid string) (*order.Orde
 		return nil, fmt.Errorf("error parsing cached order: %s", err)
 	}
 
+	err = openDelivery(r.sealer, o.Delivery)
+	if err != nil {
+		return nil, err
+	}
+
 	return &o, nil
 }
 
 func (r *RedisRepository) CreateOrder(ctx context.Context, o *order.Order) error {
-	serializedOrder, err := json.Marshal(o)
+	if !r.available() {
+		return nil
+	}
+
+	// Копия нужна, чтобы не зашифровать данные в заказе, который продолжает использоваться вызывающей стороной.
+	sealed := *o
+	if o.Delivery != nil {
+		delivery, err := sealDelivery(r.sealer, o.Delivery)
+		if err != nil {
+			return err
+		}
+
+		sealed.Delivery = delivery
+	}
+
+	serializedOrder, err := json.Marshal(&sealed)
 	if err != nil {
 		return fmt.Errorf("error marshalling order for caching: %s", err)
 	}
 
-	err = r.client.Set(ctx, o.OrderUID, serializedOrder, 0).Err()
+	err = r.client.Set(ctx, r.key(o.OrderUID), serializedOrder, r.ttl).Err()
 	if err != nil {
+		r.markUnavailable(err)
 		return fmt.Errorf("error saving order to redis: %s", err)
 	}
 
 	return nil
 }
+
+// DeleteOrder удаляет заказ из Redis. В отличие от чтения и записи, удаление выполняется даже если Redis
+// считается недоступным, чтобы не оставить в нём устаревшие данные.
+func (r *RedisRepository) DeleteOrder(ctx context.Context, uid string) error {
+	err := r.client.Del(ctx, r.key(uid)).Err()
+	if err != nil {
+		r.markUnavailable(err)
+		return fmt.Errorf("error deleting order from redis: %s", err)
+	}
+
+	return nil
+}
+
+// ScanOrderUIDs передаёт fn UID закэшированных заказов: всех или, если limit больше нуля, не более limit
+// случайно выбранных. Выборка делается командой RANDOMKEY, поэтому если в базе данных Redis много ключей
+// без префикса заказов, выбранных заказов может оказаться меньше limit.
+func (r *RedisRepository) ScanOrderUIDs(ctx context.Context, limit int, fn func(uid string) error) error {
+	if limit > 0 {
+		return r.sampleOrderUIDs(ctx, limit, fn)
+	}
+
+	iterator := r.client.Scan(ctx, 0, r.prefix+"*", 1000).Iterator()
+	for iterator.Next(ctx) {
+		err := fn(strings.TrimPrefix(iterator.Val(), r.prefix))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := iterator.Err()
+	if err != nil {
+		return fmt.Errorf("error scanning redis keys: %s", err)
+	}
+
+	return nil
+}
+
+func (r *RedisRepository) sampleOrderUIDs(ctx context.Context, limit int, fn func(uid string) error) error {
+	seen := make(map[string]struct{}, limit)
+	for attempt := 0; attempt < 2*limit && len(seen) < limit; attempt++ {
+		key, err := r.client.RandomKey(ctx).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return nil
+			}
+
+			return fmt.Errorf("error sampling redis keys: %s", err)
+		}
+
+		uid, ok := strings.CutPrefix(key, r.prefix)
+		if !ok {
+			continue
+		}
+
+		if _, ok := seen[uid]; ok {
+			continue
+		}
+		seen[uid] = struct{}{}
+
+		err = fn(uid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RedisRepository) key(uid string) string {
+	return r.prefix + uid
+}
+
+func (r *RedisRepository) available() bool {
+	return time.Now().UnixNano() >= r.unavailableUntil.Load()
+}
+
+func (r *RedisRepository) markUnavailable(err error) {
+	if r.cooldown <= 0 {
+		return
+	}
+
+	// Отмена запроса клиентом не говорит о проблемах с Redis.
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if r.available() {
+		log.Printf("redis is unavailable, bypassing it for %s: %s\n", r.cooldown, err)
+	}
+
+	r.unavailableUntil.Store(time.Now().Add(r.cooldown).UnixNano())
+}
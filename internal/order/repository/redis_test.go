@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/conformance"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const testRedisPrefix = "order:"
+
+// newTestRedis запускает miniredis и возвращает его вместе с репозиторием, работающим с ним.
+func newTestRedis(t testing.TB, cooldown time.Duration) (*miniredis.Miniredis, *RedisRepository) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	return server, NewRedisRepository(client, testRedisPrefix, time.Hour, cooldown, nil)
+}
+
+// newTestDatabase возвращает хранилище в памяти с сохранённым в нём заказом.
+func newTestDatabase(t *testing.T, uid string) (*InMemoryRepository, *order.Order) {
+	t.Helper()
+
+	database := NewInMemoryRepository()
+	o := conformance.SampleOrder(uid, 1)
+	err := database.CreateOrder(context.Background(), o)
+	if err != nil {
+		t.Fatalf("error creating order: %s", err)
+	}
+
+	return database, o
+}
+
+func assertSameOrder(t *testing.T, want, got *order.Order) {
+	t.Helper()
+
+	diff, err := order.Diff(want, got)
+	if err != nil {
+		t.Fatalf("error comparing orders: %s", err)
+	}
+
+	for _, d := range diff {
+		t.Errorf("%s: got %v, want %v", d.Path, d.Got, d.Want)
+	}
+}
+
+func TestRedisCacheFill(t *testing.T) {
+	ctx := context.Background()
+	server, redisRepo := newTestRedis(t, 0)
+	database, o := newTestDatabase(t, "fill")
+	cached := NewCachedRepository("redis", database, redisRepo)
+
+	got, err := cached.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order: %s", err)
+	}
+	assertSameOrder(t, o, got)
+
+	if !server.Exists(testRedisPrefix + o.OrderUID) {
+		t.Fatal("order is not saved to redis after cache miss")
+	}
+
+	if ttl := server.TTL(testRedisPrefix + o.OrderUID); ttl != time.Hour {
+		t.Errorf("got TTL %s, want %s", ttl, time.Hour)
+	}
+
+	// Второй запрос не должен доходить до основной базы данных.
+	_ = database.DeleteOrder(ctx, o.OrderUID)
+	got, err = cached.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order from redis: %s", err)
+	}
+	assertSameOrder(t, o, got)
+}
+
+func TestRedisCacheCreateOrder(t *testing.T) {
+	ctx := context.Background()
+	server, redisRepo := newTestRedis(t, 0)
+	cached := NewCachedRepository("redis", NewInMemoryRepository(), redisRepo)
+
+	o := conformance.SampleOrder("create", 1)
+	err := cached.CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatalf("error creating order: %s", err)
+	}
+
+	if !server.Exists(testRedisPrefix + o.OrderUID) {
+		t.Fatal("created order is not saved to redis")
+	}
+
+	got, err := redisRepo.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order from redis: %s", err)
+	}
+	assertSameOrder(t, o, got)
+}
+
+func TestRedisCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	server, redisRepo := newTestRedis(t, 0)
+	database, o := newTestDatabase(t, "invalidate")
+	redisCached := NewCachedRepository("redis", database, redisRepo)
+	memory := NewInMemoryRepository()
+	cached := NewCachedRepository("memory", redisCached, memory)
+
+	_, err := cached.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order: %s", err)
+	}
+
+	err = cached.InvalidateOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error invalidating order: %s", err)
+	}
+
+	if server.Exists(testRedisPrefix + o.OrderUID) {
+		t.Error("order is not deleted from redis")
+	}
+
+	if _, err := memory.GetOrder(ctx, o.OrderUID); !errors.Is(err, order.ErrNotFound) {
+		t.Errorf("got error %v from memory cache, want order.ErrNotFound", err)
+	}
+
+	// Изменённый заказ читается из основной базы данных, а не из кэшей.
+	changed := *o
+	changed.TrackNumber = "CHANGED"
+	_ = database.DeleteOrder(ctx, o.OrderUID)
+	_ = database.CreateOrder(ctx, &changed)
+
+	got, err := cached.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order after invalidation: %s", err)
+	}
+
+	if got.TrackNumber != changed.TrackNumber {
+		t.Errorf("got track number %q after invalidation, want %q", got.TrackNumber, changed.TrackNumber)
+	}
+}
+
+func TestRedisCacheFallback(t *testing.T) {
+	ctx := context.Background()
+	cooldown := 100 * time.Millisecond
+	server, redisRepo := newTestRedis(t, cooldown)
+	database, o := newTestDatabase(t, "fallback")
+	cached := NewCachedRepository("redis", database, redisRepo)
+
+	server.SetError("LOADING Redis is loading the dataset in memory")
+
+	got, err := cached.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order while redis is down: %s", err)
+	}
+	assertSameOrder(t, o, got)
+
+	created := conformance.SampleOrder("fallback-created", 10)
+	err = cached.CreateOrder(ctx, created)
+	if err != nil {
+		t.Fatalf("error creating order while redis is down: %s", err)
+	}
+
+	if _, err := database.GetOrder(ctx, created.OrderUID); err != nil {
+		t.Fatalf("order created while redis is down is not saved to database: %s", err)
+	}
+
+	// Пока не истекло время недоступности, Redis не используется даже после восстановления.
+	server.SetError("")
+	err = cached.CreateOrder(ctx, conformance.SampleOrder("fallback-cooldown", 20))
+	if err != nil {
+		t.Fatalf("error creating order: %s", err)
+	}
+
+	if server.Exists(testRedisPrefix + "fallback-cooldown") {
+		t.Error("redis is used before cooldown has passed")
+	}
+
+	time.Sleep(cooldown)
+
+	_, err = cached.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order after redis recovery: %s", err)
+	}
+
+	if !server.Exists(testRedisPrefix + o.OrderUID) {
+		t.Error("redis is not used after cooldown has passed")
+	}
+}
+
+func TestRedisCacheUnreachable(t *testing.T) {
+	ctx := context.Background()
+	server, redisRepo := newTestRedis(t, time.Minute)
+	database, o := newTestDatabase(t, "unreachable")
+	cached := NewCachedRepository("redis", database, redisRepo)
+
+	server.Close()
+
+	for i := 0; i < 2; i++ {
+		got, err := cached.GetOrder(ctx, o.OrderUID)
+		if err != nil {
+			t.Fatalf("error getting order while redis is unreachable: %s", err)
+		}
+		assertSameOrder(t, o, got)
+	}
+
+	if redisRepo.available() {
+		t.Error("unreachable redis is not marked as unavailable")
+	}
+}
@@ -0,0 +1,582 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+	"wb-l0/pkg/envelope"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ShardedRepository - репозиторий, распределяющий заказы между несколькими базами данных Postgres (шардами)
+// по значению Order.ShardKey. Соответствие ShardKey и шардов задаётся в конфигурации.
+//
+// При чтении по UID шард заказа определяется одним из способов (см. shardLocator): по каталогу order_shards,
+// который заполняется при сохранении заказа, или по ShardKey, закодированному в самом UID.
+//
+// Операции, не относящиеся к одному заказу (поиск изменённых заказов, удаление устаревших), выполняются
+// во всех шардах.
+type ShardedRepository struct {
+	shards       map[string]*PostgresRepository
+	names        []string
+	keys         map[string]string
+	defaultShard string
+	locator      shardLocator
+}
+
+// shardLocator определяет шард, в котором хранится заказ с известным UID.
+type shardLocator interface {
+	// locate возвращает имя шарда заказа или order.ErrNotFound, если шард неизвестен.
+	locate(ctx context.Context, uid string) (string, error)
+	// remember вызывается перед сохранением заказа в шард и сообщает, была ли при этом создана новая запись,
+	// которую нужно удалить через forget, если заказ сохранить не удалось. Если заказ с таким UID уже сохранён
+	// в другой шард, возвращает order.ErrAlreadyExists.
+	remember(ctx context.Context, uid string, shard string) (bool, error)
+	// forget вызывается после удаления заказов из шарда.
+	forget(ctx context.Context, uids []string) error
+}
+
+func NewShardedRepositoryFromConfig(
+	ctx context.Context, cfg config.Sharding, postgres config.PostgresConnection, sealer *envelope.Sealer,
+) (*ShardedRepository, error) {
+	if len(cfg.Shards) == 0 {
+		return nil, errors.New("no shards are configured")
+	}
+
+	r := &ShardedRepository{
+		shards:       make(map[string]*PostgresRepository),
+		keys:         cfg.Keys,
+		defaultShard: cfg.DefaultShard,
+	}
+
+	for name := range cfg.Shards {
+		r.names = append(r.names, name)
+	}
+	sort.Strings(r.names)
+
+	for _, shard := range append(mapValues(cfg.Keys), cfg.DefaultShard) {
+		if _, ok := cfg.Shards[shard]; shard != "" && !ok {
+			return nil, fmt.Errorf("unknown shard \"%s\" in shard map", shard)
+		}
+	}
+
+	for _, name := range r.names {
+		// Реплики задаются для одной базы данных и не применимы к шардам.
+		shardCfg := postgres
+		shardCfg.URL = cfg.Shards[name]
+		shardCfg.ReplicaURLs = nil
+
+		shard, err := NewPostgresRepositoryFromConfig(ctx, shardCfg, sealer)
+		if err != nil {
+			r.close()
+			return nil, fmt.Errorf("error connecting to shard %s: %s", name, err)
+		}
+
+		r.shards[name] = shard
+	}
+
+	switch cfg.Locator {
+	case "directory":
+		directory, ok := r.shards[cfg.DirectoryShard]
+		if !ok {
+			r.close()
+			return nil, fmt.Errorf("unknown directory shard \"%s\"", cfg.DirectoryShard)
+		}
+
+		r.locator = &directoryLocator{repository: directory}
+	case "uid":
+		pattern, err := regexp.Compile(cfg.UIDPattern)
+		if err != nil || pattern.NumSubexp() < 1 {
+			r.close()
+			return nil, fmt.Errorf("uid pattern must be a valid regular expression with a group: %s", cfg.UIDPattern)
+		}
+
+		r.locator = &uidLocator{pattern: pattern, shardFor: r.shardFor}
+	default:
+		r.close()
+		return nil, fmt.Errorf("unknown shard locator \"%s\"", cfg.Locator)
+	}
+
+	return r, nil
+}
+
+func (r *ShardedRepository) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
+	shard, err := r.locate(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return shard.GetOrder(ctx, uid)
+}
+
+func (r *ShardedRepository) CreateOrder(ctx context.Context, o *order.Order) error {
+	return r.CreateOrderWithMessage(ctx, o, nil)
+}
+
+// CreateOrderWithMessage сохраняет заказ вместе с исходным сообщением в шард заказа.
+func (r *ShardedRepository) CreateOrderWithMessage(ctx context.Context, o *order.Order, message *order.RawMessage) error {
+	name, err := r.shardFor(o.ShardKey)
+	if err != nil {
+		return err
+	}
+
+	remembered, err := r.locator.remember(ctx, o.OrderUID, name)
+	if err != nil {
+		return err
+	}
+
+	err = r.shards[name].CreateOrderWithMessage(ctx, o, message)
+	if err != nil && remembered && !errors.Is(err, order.ErrAlreadyExists) {
+		r.forgetFailed(ctx, []string{o.OrderUID})
+	}
+
+	return err
+}
+
+// CreateOrders сохраняет заказы пакетами, по одному на шард (см. PostgresRepository.CreateOrders). Для заказов,
+// шард которых не удалось определить или запомнить, возвращается ошибка сохранения. Если возвращена ошибка err,
+// пакеты части шардов могли быть уже сохранены.
+func (r *ShardedRepository) CreateOrders(
+	ctx context.Context, orders []*order.Order, messages []*order.RawMessage,
+) ([]error, error) {
+	errs := make([]error, len(orders))
+	remembered := make([]bool, len(orders))
+	batches := make(map[string][]int)
+	for i, o := range orders {
+		name, err := r.shardFor(o.ShardKey)
+		if err == nil {
+			remembered[i], err = r.locator.remember(ctx, o.OrderUID, name)
+		}
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		batches[name] = append(batches[name], i)
+	}
+
+	// Записи каталога о заказах, которые не удалось сохранить, удаляются, чтобы их можно было сохранить повторно.
+	var failed []string
+	defer func() { r.forgetFailed(ctx, failed) }()
+
+	for _, name := range r.names {
+		indexes := batches[name]
+		if len(indexes) == 0 {
+			continue
+		}
+
+		batch := make([]*order.Order, len(indexes))
+		var batchMessages []*order.RawMessage
+		if messages != nil {
+			batchMessages = make([]*order.RawMessage, len(indexes))
+		}
+		for j, i := range indexes {
+			batch[j] = orders[i]
+			if messages != nil {
+				batchMessages[j] = messages[i]
+			}
+		}
+
+		shardErrs, err := r.shards[name].CreateOrders(ctx, batch, batchMessages)
+		if err != nil {
+			for _, i := range indexes {
+				if remembered[i] {
+					failed = append(failed, orders[i].OrderUID)
+				}
+			}
+
+			return nil, fmt.Errorf("error saving orders to shard %s: %s", name, err)
+		}
+
+		for j, i := range indexes {
+			errs[i] = shardErrs[j]
+			if errs[i] != nil && remembered[i] && !errors.Is(errs[i], order.ErrAlreadyExists) {
+				failed = append(failed, orders[i].OrderUID)
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+// GetRawMessage возвращает исходное сообщение, из которого был получен заказ.
+func (r *ShardedRepository) GetRawMessage(ctx context.Context, uid string) (*order.RawMessage, error) {
+	shard, err := r.locate(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return shard.GetRawMessage(ctx, uid)
+}
+
+// ExportOrders выгружает заказы из всех шардов по очереди. Порядок создания соблюдается только в пределах шарда.
+func (r *ShardedRepository) ExportOrders(
+	ctx context.Context, filter order.Filter, fn func(o *order.Order) error,
+) error {
+	for _, name := range r.names {
+		err := r.shards[name].ExportOrders(ctx, filter, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ChangeOrderState меняет состояние заказа в его шарде.
+func (r *ShardedRepository) ChangeOrderState(
+	ctx context.Context, change order.StateChange,
+) (*order.StateTransition, int64, error) {
+	shard, err := r.locate(ctx, change.OrderUID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return shard.ChangeOrderState(ctx, change)
+}
+
+// GetStateTransitions возвращает историю смены состояний заказа из его шарда.
+func (r *ShardedRepository) GetStateTransitions(ctx context.Context, uid string) ([]*order.StateTransition, error) {
+	shard, err := r.locate(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return shard.GetStateTransitions(ctx, uid)
+}
+
+// ApplyItemStatus меняет статус товара в шарде заказа.
+func (r *ShardedRepository) ApplyItemStatus(ctx context.Context, change order.ItemStatusChange) (int64, error) {
+	shard, err := r.locate(ctx, change.OrderUID)
+	if err != nil {
+		return 0, err
+	}
+
+	return shard.ApplyItemStatus(ctx, change)
+}
+
+// GetItemStatusHistory возвращает историю статусов товара из шарда заказа.
+func (r *ShardedRepository) GetItemStatusHistory(
+	ctx context.Context, uid string, chrtID int64,
+) ([]*order.ItemStatusRecord, error) {
+	shard, err := r.locate(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return shard.GetItemStatusHistory(ctx, uid, chrtID)
+}
+
+// ChangedOrderUIDs возвращает UID заказов, изменённых начиная с момента since, во всех шардах.
+func (r *ShardedRepository) ChangedOrderUIDs(ctx context.Context, since time.Time) ([]string, error) {
+	var uids []string
+	for _, name := range r.names {
+		changed, err := r.shards[name].ChangedOrderUIDs(ctx, since)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %s", name, err)
+		}
+
+		uids = append(uids, changed...)
+	}
+
+	return uids, nil
+}
+
+// PurgeOrders удаляет не более limit заказов, созданных раньше before, обходя шарды по очереди.
+func (r *ShardedRepository) PurgeOrders(ctx context.Context, before time.Time, limit int, archive bool) ([]string, error) {
+	var purged []string
+	for _, name := range r.names {
+		if len(purged) >= limit {
+			break
+		}
+
+		uids, err := r.shards[name].PurgeOrders(ctx, before, limit-len(purged), archive)
+		if err != nil {
+			return purged, fmt.Errorf("shard %s: %s", name, err)
+		}
+
+		err = r.locator.forget(ctx, uids)
+		if err != nil {
+			return purged, err
+		}
+
+		purged = append(purged, uids...)
+	}
+
+	return purged, nil
+}
+
+// ReencryptDeliveries перешифровывает персональные данные доставок во всех шардах
+// (см. PostgresRepository.ReencryptDeliveries).
+func (r *ShardedRepository) ReencryptDeliveries(ctx context.Context, batchSize int) (int, error) {
+	return r.reencrypt(func(shard *PostgresRepository) (int, error) {
+		return shard.ReencryptDeliveries(ctx, batchSize)
+	})
+}
+
+// ReencryptRawMessages перешифровывает тела исходных сообщений во всех шардах.
+func (r *ShardedRepository) ReencryptRawMessages(ctx context.Context, batchSize int) (int, error) {
+	return r.reencrypt(func(shard *PostgresRepository) (int, error) {
+		return shard.ReencryptRawMessages(ctx, batchSize)
+	})
+}
+
+// ReencryptArchive перешифровывает персональные данные архивных заказов во всех шардах.
+func (r *ShardedRepository) ReencryptArchive(ctx context.Context, batchSize int) (int, error) {
+	return r.reencrypt(func(shard *PostgresRepository) (int, error) {
+		return shard.ReencryptArchive(ctx, batchSize)
+	})
+}
+
+// reencrypt выполняет перешифровку fn в шардах по очереди и возвращает общее количество перешифрованных записей,
+// в том числе при ошибке в одном из шардов.
+func (r *ShardedRepository) reencrypt(fn func(shard *PostgresRepository) (int, error)) (int, error) {
+	total := 0
+	for _, name := range r.names {
+		count, err := fn(r.shards[name])
+		total += count
+		if err != nil {
+			return total, fmt.Errorf("error re-encrypting shard %s: %s", name, err)
+		}
+	}
+
+	return total, nil
+}
+
+// SearchOrders выполняет поиск во всех шардах и объединяет результаты в общем порядке.
+func (r *ShardedRepository) SearchOrders(ctx context.Context, query order.SearchQuery) ([]*order.Summary, error) {
+	if query.After != nil && query.Text != "" {
+		return nil, order.ErrCursorWithText
+	}
+
+	var summaries []*order.Summary
+	for _, name := range r.names {
+		found, err := r.shards[name].SearchOrders(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %s", name, err)
+		}
+
+		summaries = append(summaries, found...)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].Rank != summaries[j].Rank {
+			return summaries[i].Rank > summaries[j].Rank
+		}
+
+		return order.CursorAfter(summaries[i]).Before(summaries[j])
+	})
+
+	return summaries[:min(len(summaries), query.PageSize())], nil
+}
+
+// Sales рассчитывает показатели продаж в каждом шарде и складывает показатели одинаковых групп.
+func (r *ShardedRepository) Sales(ctx context.Context, query order.SalesQuery) ([]*order.SalesRow, error) {
+	groups := make(map[string]*order.SalesRow)
+	var keys []string
+	for _, name := range r.names {
+		rows, err := r.shards[name].Sales(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %s", name, err)
+		}
+
+		for _, row := range rows {
+			key := salesGroupKey(row)
+			group, ok := groups[key]
+			if !ok {
+				copied := *row
+				groups[key] = &copied
+				keys = append(keys, key)
+				continue
+			}
+
+			group.OrderCount += row.OrderCount
+			group.Revenue += row.Revenue
+			group.DeliveryCost += row.DeliveryCost
+		}
+	}
+
+	sort.Strings(keys)
+	result := make([]*order.SalesRow, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		group.AverageBasket = group.Revenue.DivideRounded(group.OrderCount)
+		result = append(result, group)
+	}
+
+	return result, nil
+}
+
+func salesGroupKey(row *order.SalesRow) string {
+	var period string
+	if row.Period != nil {
+		period = row.Period.UTC().Format(time.RFC3339)
+	}
+
+	str := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+
+	return strings.Join([]string{period, str(row.Brand), str(row.DeliveryService), str(row.Region), row.Currency}, "\x00")
+}
+
+func (r *ShardedRepository) locate(ctx context.Context, uid string) (*PostgresRepository, error) {
+	name, err := r.locator.locate(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	shard, ok := r.shards[name]
+	if !ok {
+		return nil, fmt.Errorf("order %s is stored in unknown shard \"%s\"", uid, name)
+	}
+
+	return shard, nil
+}
+
+// forgetFailed удаляет записи о шардах заказов, которые не удалось сохранить. Ошибка только записывается
+// в журнал: сохранение заказа уже завершилось ошибкой.
+func (r *ShardedRepository) forgetFailed(ctx context.Context, uids []string) {
+	err := r.locator.forget(ctx, uids)
+	if err != nil {
+		log.Printf("error forgetting shards of orders that were not saved: %s\n", err)
+	}
+}
+
+func (r *ShardedRepository) shardFor(shardKey string) (string, error) {
+	if name, ok := r.keys[shardKey]; ok {
+		return name, nil
+	}
+
+	if r.defaultShard == "" {
+		return "", fmt.Errorf("there is no shard for shard key \"%s\"", shardKey)
+	}
+
+	return r.defaultShard, nil
+}
+
+func (r *ShardedRepository) close() {
+	for _, shard := range r.shards {
+		shard.pool.Close()
+	}
+}
+
+func mapValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// directoryLocator хранит шард каждого заказа в таблице order_shards одного из шардов.
+type directoryLocator struct {
+	repository *PostgresRepository
+}
+
+const (
+	locateShardQuery   = `select shard from order_shards where order_uid = $1`
+	rememberShardQuery = `insert into order_shards (order_uid, shard) values ($1, $2) on conflict (order_uid) do nothing`
+	forgetShardsQuery  = `delete from order_shards where order_uid = any($1)`
+)
+
+func (d *directoryLocator) locate(ctx context.Context, uid string) (string, error) {
+	var shard string
+	err := d.repository.pool.QueryRow(ctx, locateShardQuery, uid).Scan(&shard)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", order.ErrNotFound
+		}
+
+		return "", fmt.Errorf("error locating order shard: %s", err)
+	}
+
+	return shard, nil
+}
+
+func (d *directoryLocator) remember(ctx context.Context, uid string, shard string) (bool, error) {
+	tag, err := d.repository.pool.Exec(ctx, rememberShardQuery, uid, shard)
+	if err != nil {
+		return false, fmt.Errorf("error saving order shard: %s", err)
+	}
+
+	if tag.RowsAffected() == 1 {
+		return true, nil
+	}
+
+	// Запись уже существовала. Если она указывает на другой шард, заказ с таким UID там уже есть.
+	existing, err := d.locate(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+
+	if existing != shard {
+		return false, order.ErrAlreadyExists
+	}
+
+	return false, nil
+}
+
+func (d *directoryLocator) forget(ctx context.Context, uids []string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+
+	_, err := d.repository.pool.Exec(ctx, forgetShardsQuery, uids)
+	if err != nil {
+		return fmt.Errorf("error deleting order shards: %s", err)
+	}
+
+	return nil
+}
+
+// uidLocator определяет шард по ShardKey, извлекаемому из UID заказа регулярным выражением. Заказы, UID
+// которых не соответствует их ShardKey, не сохраняются, так как их нельзя было бы найти.
+type uidLocator struct {
+	pattern  *regexp.Regexp
+	shardFor func(shardKey string) (string, error)
+}
+
+func (u *uidLocator) locate(_ context.Context, uid string) (string, error) {
+	match := u.pattern.FindStringSubmatch(uid)
+	if match == nil {
+		return "", order.ErrNotFound
+	}
+
+	shard, err := u.shardFor(match[1])
+	if err != nil {
+		return "", order.ErrNotFound
+	}
+
+	return shard, nil
+}
+
+func (u *uidLocator) remember(ctx context.Context, uid string, shard string) (bool, error) {
+	located, err := u.locate(ctx, uid)
+	if err != nil {
+		return false, fmt.Errorf("shard of order %s cannot be determined from its uid", uid)
+	}
+
+	if located != shard {
+		return false, fmt.Errorf("order %s uid points to shard %s, but its shard key points to shard %s", uid, located, shard)
+	}
+
+	return false, nil
+}
+
+func (*uidLocator) forget(context.Context, []string) error {
+	return nil
+}
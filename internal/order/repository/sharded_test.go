@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/conformance"
+)
+
+func TestShardedDuplicateInOtherShard(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestSharded(t)
+
+	o := conformance.SampleOrder("sharded-duplicate", 1)
+	err := repo.CreateOrder(ctx, o)
+	if err != nil {
+		t.Fatalf("error creating order: %s", err)
+	}
+
+	// Тот же UID с другим ShardKey попадает в другой шард, но всё равно считается повторным сохранением.
+	duplicate := conformance.SampleOrder(o.OrderUID, 10)
+	duplicate.ShardKey = "other"
+	if err := repo.CreateOrder(ctx, duplicate); !errors.Is(err, order.ErrAlreadyExists) {
+		t.Errorf("got error %v, want order.ErrAlreadyExists", err)
+	}
+
+	errs, err := repo.CreateOrders(ctx, []*order.Order{duplicate}, nil)
+	if err != nil {
+		t.Fatalf("error creating orders: %s", err)
+	}
+
+	if !errors.Is(errs[0], order.ErrAlreadyExists) {
+		t.Errorf("got error %v from batch, want order.ErrAlreadyExists", errs[0])
+	}
+}
+
+func TestShardedForgetsFailedOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestSharded(t)
+
+	existing := conformance.SampleOrder("sharded-existing", 1)
+	err := repo.CreateOrder(ctx, existing)
+	if err != nil {
+		t.Fatalf("error creating order: %s", err)
+	}
+
+	// Заказ с уже занятым chrt_id не сохраняется в шард "b", а запись каталога о нём удаляется,
+	// поэтому исправленный заказ можно сохранить в другой шард.
+	failed := conformance.SampleOrder("sharded-failed", 1)
+	if err := repo.CreateOrder(ctx, failed); err == nil || errors.Is(err, order.ErrAlreadyExists) {
+		t.Fatalf("got error %v, want chrt_id conflict", err)
+	}
+
+	batchFailed := conformance.SampleOrder("sharded-batch-failed", 1)
+	errs, err := repo.CreateOrders(ctx, []*order.Order{batchFailed}, nil)
+	if err != nil || errs[0] == nil {
+		t.Fatalf("got errors %v, %v, want chrt_id conflict", errs, err)
+	}
+
+	for i, o := range []*order.Order{failed, batchFailed} {
+		o.ShardKey = "other"
+		for _, item := range o.Items {
+			item.ChrtID += int64(100 * (i + 1))
+		}
+
+		err = repo.CreateOrder(ctx, o)
+		if err != nil {
+			t.Fatalf("error creating corrected order %s: %s", o.OrderUID, err)
+		}
+
+		if _, err := repo.GetOrder(ctx, o.OrderUID); err != nil {
+			t.Errorf("error getting corrected order %s: %s", o.OrderUID, err)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wb-l0/internal/order"
+	"wb-l0/pkg/envelope"
+)
+
+const (
+	snapshotMagic   = "WBL0SNAP"
+	snapshotVersion = 1
+
+	// snapshotClockSkew - запас при сверке снимка с базой данных на случай расхождения часов сервиса и Postgres.
+	snapshotClockSkew = time.Minute
+)
+
+// snapshotHeader - заголовок файла снимка. За ним следует сжатый gzip JSON-массив заказов,
+// контрольная сумма которого записана в заголовке.
+type snapshotHeader struct {
+	Magic     [8]byte
+	Version   uint16
+	CreatedAt int64
+	Checksum  [sha256.Size]byte
+}
+
+// Snapshotter - сохраняет содержимое InMemoryRepository в файл при остановке сервиса и восстанавливает его
+// при запуске, чтобы не прогревать кэш заново.
+//
+// Заказы, изменённые в основной базе данных после создания снимка, после загрузки удаляются из кэша,
+// чтобы не отдавать устаревшие данные. При следующем запросе они будут получены из базы данных.
+//
+// Персональные данные доставки записываются в снимок зашифрованными, если задан sealer.
+type Snapshotter struct {
+	path     string
+	cache    *InMemoryRepository
+	database order.ChangeTracker
+	sealer   *envelope.Sealer
+}
+
+func NewSnapshotter(
+	path string, cache *InMemoryRepository, database order.ChangeTracker, sealer *envelope.Sealer,
+) *Snapshotter {
+	return &Snapshotter{path: path, cache: cache, database: database, sealer: sealer}
+}
+
+// Save записывает снимок кэша. Файл сначала пишется во временный файл рядом с итоговым и затем
+// переименовывается, чтобы прерванная запись не испортила предыдущий снимок.
+func (s *Snapshotter) Save() error {
+	createdAt := time.Now()
+
+	var orders []*order.Order
+	var err error
+	s.cache.store.Range(func(_, value any) bool {
+		// Копия нужна, чтобы не зашифровать данные в заказе, который продолжает храниться в кэше.
+		sealed := *value.(*order.Order)
+		if sealed.Delivery != nil {
+			sealed.Delivery, err = sealDelivery(s.sealer, sealed.Delivery)
+			if err != nil {
+				err = fmt.Errorf("order %s: %s", sealed.OrderUID, err)
+				return false
+			}
+		}
+
+		orders = append(orders, &sealed)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	var payload bytes.Buffer
+	writer := gzip.NewWriter(&payload)
+	err = json.NewEncoder(writer).Encode(orders)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %s", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("error compressing snapshot: %s", err)
+	}
+
+	header := snapshotHeader{
+		Version:   snapshotVersion,
+		CreatedAt: createdAt.UnixNano(),
+		Checksum:  sha256.Sum256(payload.Bytes()),
+	}
+	copy(header.Magic[:], snapshotMagic)
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot file: %s", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	err = binary.Write(file, binary.BigEndian, &header)
+	if err != nil {
+		return fmt.Errorf("error writing snapshot header: %s", err)
+	}
+
+	_, err = file.Write(payload.Bytes())
+	if err != nil {
+		return fmt.Errorf("error writing snapshot: %s", err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return fmt.Errorf("error syncing snapshot file: %s", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("error closing snapshot file: %s", err)
+	}
+
+	err = os.Rename(file.Name(), s.path)
+	if err != nil {
+		return fmt.Errorf("error replacing snapshot file: %s", err)
+	}
+
+	log.Printf("saved cache snapshot with %d orders to %s\n", len(orders), s.path)
+	return nil
+}
+
+// Load загружает снимок в кэш и сверяет его с основной базой данных. Отсутствие файла снимка ошибкой не считается.
+func (s *Snapshotter) Load(ctx context.Context) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Println("cache snapshot not found, starting with empty cache")
+			return nil
+		}
+
+		return fmt.Errorf("error opening snapshot file: %s", err)
+	}
+	defer file.Close()
+
+	var header snapshotHeader
+	err = binary.Read(file, binary.BigEndian, &header)
+	if err != nil {
+		return fmt.Errorf("error reading snapshot header: %s", err)
+	}
+
+	if string(header.Magic[:]) != snapshotMagic {
+		return errors.New("file is not a cache snapshot")
+	}
+
+	if header.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+
+	payload, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("error reading snapshot: %s", err)
+	}
+
+	if sha256.Sum256(payload) != header.Checksum {
+		return errors.New("snapshot checksum mismatch")
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error decompressing snapshot: %s", err)
+	}
+
+	var orders []*order.Order
+	err = json.NewDecoder(reader).Decode(&orders)
+	if err != nil {
+		return fmt.Errorf("error decoding snapshot: %s", err)
+	}
+
+	// Снимки, сохранённые до включения шифрования, содержат открытые данные и загружаются как есть.
+	for _, o := range orders {
+		err = openDelivery(s.sealer, o.Delivery)
+		if err != nil {
+			return fmt.Errorf("error decrypting order %s from snapshot: %s", o.OrderUID, err)
+		}
+	}
+
+	for _, o := range orders {
+		s.cache.store.Store(o.OrderUID, o)
+	}
+
+	createdAt := time.Unix(0, header.CreatedAt)
+	log.Printf("loaded cache snapshot with %d orders created at %s\n", len(orders), createdAt)
+
+	return s.reconcile(ctx, createdAt)
+}
+
+func (s *Snapshotter) reconcile(ctx context.Context, createdAt time.Time) error {
+	uids, err := s.database.ChangedOrderUIDs(ctx, createdAt.Add(-snapshotClockSkew))
+	if err != nil {
+		// Нельзя гарантировать актуальность снимка, поэтому лучше начать с пустого кэша.
+		s.cache.Clear()
+		return fmt.Errorf("error fetching orders changed since snapshot: %s", err)
+	}
+
+	for _, uid := range uids {
+		s.cache.store.Delete(uid)
+	}
+
+	log.Printf("evicted %d orders changed since snapshot\n", len(uids))
+	return nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-l0/internal/order/conformance"
+	"wb-l0/pkg/envelope"
+)
+
+// newTestKeys возвращает случайные ключи шифрования с указанными идентификаторами.
+func newTestKeys(ids ...string) map[string][]byte {
+	keys := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		keys[id] = key
+	}
+
+	return keys
+}
+
+// newTestSealer возвращает Sealer со связкой из ключей keys и активным ключом active. Если keys не переданы,
+// связка состоит из одного случайного ключа active.
+func newTestSealer(t *testing.T, active string, keys map[string][]byte) *envelope.Sealer {
+	t.Helper()
+
+	if keys == nil {
+		keys = newTestKeys(active)
+	}
+
+	keyring, err := envelope.NewKeyring(active, keys)
+	if err != nil {
+		t.Fatalf("error creating keyring: %s", err)
+	}
+
+	return envelope.NewSealer(keyring)
+}
+
+// noChanges - основная база данных, в которой заказы не менялись после создания снимка.
+type noChanges struct{}
+
+func (noChanges) ChangedOrderUIDs(context.Context, time.Time) ([]string, error) {
+	return nil, nil
+}
+
+func TestSnapshotEncryptsDelivery(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	sealer := newTestSealer(t, "key-1", nil)
+
+	cache := NewInMemoryRepository()
+	o := conformance.SampleOrder("snapshot", 1)
+	_ = cache.CreateOrder(ctx, o)
+
+	err := NewSnapshotter(path, cache, noChanges{}, sealer).Save()
+	if err != nil {
+		t.Fatalf("error saving snapshot: %s", err)
+	}
+
+	// Заказ в кэше не должен зашифроваться вместе со снимком.
+	if got, _ := cache.GetOrder(ctx, o.OrderUID); got.Delivery.Email != "test@gmail.com" {
+		t.Errorf("cached order is modified by snapshot: email is %q", got.Delivery.Email)
+	}
+
+	content := readSnapshotPayload(t, path)
+	for _, value := range []string{o.Delivery.Name, o.Delivery.Phone, o.Delivery.Address, o.Delivery.Email} {
+		if strings.Contains(content, value) {
+			t.Errorf("snapshot contains plaintext %q", value)
+		}
+	}
+
+	restored := NewInMemoryRepository()
+	err = NewSnapshotter(path, restored, noChanges{}, sealer).Load(ctx)
+	if err != nil {
+		t.Fatalf("error loading snapshot: %s", err)
+	}
+
+	got, err := restored.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order from restored cache: %s", err)
+	}
+	assertSameOrder(t, o, got)
+}
+
+func TestSnapshotWithoutKeyring(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	cache := NewInMemoryRepository()
+	_ = cache.CreateOrder(ctx, conformance.SampleOrder("snapshot", 1))
+
+	err := NewSnapshotter(path, cache, noChanges{}, newTestSealer(t, "key-1", nil)).Save()
+	if err != nil {
+		t.Fatalf("error saving snapshot: %s", err)
+	}
+
+	// Зашифрованный снимок нельзя загрузить без ключей, и кэш при этом остаётся пустым.
+	restored := NewInMemoryRepository()
+	err = NewSnapshotter(path, restored, noChanges{}, nil).Load(ctx)
+	if err == nil {
+		t.Fatal("encrypted snapshot is loaded without keyring")
+	}
+
+	if _, err := restored.GetOrder(ctx, "snapshot"); err == nil {
+		t.Error("cache is filled from snapshot that failed to load")
+	}
+}
+
+func TestSnapshotPlaintextCompatibility(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	cache := NewInMemoryRepository()
+	o := conformance.SampleOrder("snapshot", 1)
+	_ = cache.CreateOrder(ctx, o)
+
+	// Снимок, сохранённый до включения шифрования, загружается после его включения.
+	err := NewSnapshotter(path, cache, noChanges{}, nil).Save()
+	if err != nil {
+		t.Fatalf("error saving snapshot: %s", err)
+	}
+
+	restored := NewInMemoryRepository()
+	err = NewSnapshotter(path, restored, noChanges{}, newTestSealer(t, "key-1", nil)).Load(ctx)
+	if err != nil {
+		t.Fatalf("error loading snapshot: %s", err)
+	}
+
+	got, err := restored.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order from restored cache: %s", err)
+	}
+	assertSameOrder(t, o, got)
+}
+
+// readSnapshotPayload возвращает распакованное содержимое снимка.
+func readSnapshotPayload(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading snapshot: %s", err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(content[binary.Size(snapshotHeader{}):]))
+	if err != nil {
+		t.Fatalf("error decompressing snapshot: %s", err)
+	}
+
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("error decompressing snapshot: %s", err)
+	}
+
+	return string(payload)
+}
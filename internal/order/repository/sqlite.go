@@ -0,0 +1,452 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+	"wb-l0/pkg/envelope"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+// SQLiteRepository - хранилище заказов во встроенной базе данных SQLite, позволяющее запускать сервис
+// одним исполняемым файлом без Postgres: для демонстраций, небольших инсталляций и тестов.
+//
+// Использует те же таблицы, что и PostgresRepository. Схема создаётся при открытии базы данных.
+// Уведомления об изменениях заказов не отправляются: предполагается, что с базой данных работает
+// единственный экземпляр сервиса. Состояние и версия заказа сохраняются, но не меняются: смена состояний
+// и статусов товаров не поддерживается.
+type SQLiteRepository struct {
+	db     *sql.DB
+	sealer *envelope.Sealer
+}
+
+func NewSQLiteRepository(db *sql.DB, sealer *envelope.Sealer) *SQLiteRepository {
+	return &SQLiteRepository{db: db, sealer: sealer}
+}
+
+func NewSQLiteRepositoryFromConfig(ctx context.Context, cfg config.SQLite, sealer *envelope.Sealer) (*SQLiteRepository, error) {
+	dsn := "file:" + cfg.Path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite database: %s", err)
+	}
+
+	// SQLite допускает только одного писателя, а при большом количестве соединений запись будет постоянно
+	// упираться в блокировку. Одно соединение к тому же необходимо для баз данных в памяти.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating sqlite schema: %s", err)
+	}
+
+	err = upgradeSQLiteSchema(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error upgrading sqlite schema: %s", err)
+	}
+
+	return NewSQLiteRepository(db, sealer), nil
+}
+
+// sqliteAddedColumns - столбцы, добавленные в схему после её создания, вместе с запросами, которые добавляют их
+// в базы данных, созданные раньше. Запросы выполняются, только если столбца ещё нет.
+var sqliteAddedColumns = []struct {
+	table, column string
+	queries       []string
+}{
+	{"orders", "state", []string{"alter table orders add column state text not null default 'created'"}},
+	{"orders", "state_changed_at", []string{
+		"alter table orders add column state_changed_at timestamp",
+		// Заказы, сохранённые до появления состояний, перешли в начальное состояние в момент сохранения.
+		"update orders set state_changed_at = updated_at where state_changed_at is null",
+	}},
+	{"orders", "version", []string{"alter table orders add column version integer not null default 1"}},
+}
+
+// upgradeSQLiteSchema добавляет в таблицы, созданные прежними версиями схемы, недостающие столбцы.
+func upgradeSQLiteSchema(ctx context.Context, db *sql.DB) error {
+	for _, added := range sqliteAddedColumns {
+		var exists bool
+		err := db.QueryRowContext(
+			ctx, "select exists(select 1 from pragma_table_info(?) where name = ?)", added.table, added.column,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error checking column %s.%s: %s", added.table, added.column, err)
+		}
+
+		if exists {
+			continue
+		}
+
+		for _, query := range added.queries {
+			_, err := db.ExecContext(ctx, query)
+			if err != nil {
+				return fmt.Errorf("error adding column %s.%s: %s", added.table, added.column, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+const sqliteGetOrderQuery = `
+select o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, o.customer_id, o.delivery_service,
+       o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.state, o.state_changed_at, o.version,
+       d.id, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+       p."transaction", p.request_id, p.currency, p.provider, p.amount, p.payment_dt, p.bank, p.delivery_cost,
+       p.goods_total, p.custom_fee
+from orders o
+         join deliveries d on d.id = o.delivery_id
+         join payments p on p."transaction" = o."transaction"
+where o.order_uid = ?`
+
+const sqliteGetOrderItemsQuery = `
+select chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid
+from items where order_uid = ?
+order by chrt_id`
+
+func (r *SQLiteRepository) GetOrder(ctx context.Context, uid string) (*order.Order, error) {
+	o, err := r.getOrder(ctx, r.db, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = openDelivery(r.sealer, o.Delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// sqliteQuerier - общая часть *sql.DB и *sql.Tx.
+type sqliteQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (*SQLiteRepository) getOrder(ctx context.Context, db sqliteQuerier, uid string) (*order.Order, error) {
+	o := order.Order{Delivery: &order.Delivery{}, Payment: &order.Payment{}}
+	d, p := o.Delivery, o.Payment
+	err := db.QueryRowContext(ctx, sqliteGetOrderQuery, uid).Scan(
+		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature, &o.CustomerID, &o.DeliveryService,
+		&o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard, &o.State, &o.StateChangedAt, &o.Version,
+		&d.ID, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email,
+		&p.Transaction, &p.RequestID, &p.Currency, &p.Provider, &p.Amount, &p.PaymentDt, &p.Bank, &p.DeliveryCost,
+		&p.GoodsTotal, &p.CustomFee,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, order.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("error fetching order from database: %s", err)
+	}
+
+	rows, err := db.QueryContext(ctx, sqliteGetOrderItemsQuery, uid)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order items from database: %s", err)
+	}
+	defer rows.Close()
+
+	o.Items = []*order.Item{}
+	for rows.Next() {
+		var i order.Item
+		err := rows.Scan(
+			&i.ChrtID, &i.TrackNumber, &i.Price, &i.RID, &i.Name, &i.Sale, &i.Size, &i.TotalPrice, &i.NmID, &i.Brand,
+			&i.Status, &i.OrderUID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning order item: %s", err)
+		}
+
+		o.Items = append(o.Items, &i)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order items from database: %s", err)
+	}
+
+	return &o, nil
+}
+
+const sqliteCreatePaymentQuery = `
+insert into payments
+    ("transaction", request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+    values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const sqliteCreateDeliveryQuery = `
+insert into deliveries (name, phone, zip, city, address, region, email)
+    values (?, ?, ?, ?, ?, ?, ?) returning id`
+
+const sqliteCreateOrderQuery = `
+insert into orders
+    (order_uid, track_number, entry, delivery_id, "transaction", locale, internal_signature, customer_id,
+     delivery_service, shardkey, sm_id, date_created, oof_shard, updated_at, state, state_changed_at, version)
+    values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const sqliteCreateItemQuery = `
+insert into items
+    (chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, order_uid)
+    values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// CreateOrder сохраняет заказ. Если заказ с таким UID уже сохранён, возвращает order.ErrAlreadyExists.
+func (r *SQLiteRepository) CreateOrder(ctx context.Context, o *order.Order) error {
+	return r.CreateOrderWithMessage(ctx, o, nil)
+}
+
+// CreateOrderWithMessage сохраняет заказ и, если message не nil, исходное сообщение в одной транзакции.
+func (r *SQLiteRepository) CreateOrderWithMessage(ctx context.Context, o *order.Order, message *order.RawMessage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "select exists(select 1 from orders where order_uid = ?)", o.OrderUID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking order existence: %s", err)
+	}
+
+	if exists {
+		return order.ErrAlreadyExists
+	}
+
+	// Состояние, переданное отправителем, сохраняется как есть. Смена состояний в SQLite не поддерживается,
+	// поэтому версия сохранённого заказа всегда первая.
+	now := time.Now().UTC()
+	o.InitState(now)
+	o.Version = 1
+
+	p := o.Payment
+	_, err = tx.ExecContext(
+		ctx, sqliteCreatePaymentQuery,
+		p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount, p.PaymentDt, p.Bank, p.DeliveryCost,
+		p.GoodsTotal, p.CustomFee,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving payment in database: %s", err)
+	}
+
+	d, err := sealDelivery(r.sealer, o.Delivery)
+	if err != nil {
+		return fmt.Errorf("error saving delivery in database: %s", err)
+	}
+
+	err = tx.QueryRowContext(
+		ctx, sqliteCreateDeliveryQuery,
+		d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+	).Scan(&o.Delivery.ID)
+	if err != nil {
+		return fmt.Errorf("error saving delivery in database: %s", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx, sqliteCreateOrderQuery,
+		o.OrderUID, o.TrackNumber, o.Entry, o.Delivery.ID, p.Transaction, o.Locale, o.InternalSignature,
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated.UTC(), o.OofShard, now,
+		o.State, o.StateChangedAt.UTC(), o.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving order in database: %s", err)
+	}
+
+	for _, item := range o.Items {
+		item.OrderUID = o.OrderUID
+		_, err := tx.ExecContext(
+			ctx, sqliteCreateItemQuery,
+			item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice,
+			item.NmID, item.Brand, item.Status, item.OrderUID,
+		)
+		if err != nil {
+			return fmt.Errorf("error saving item %d in database: %s", item.ChrtID, err)
+		}
+	}
+
+	if message != nil {
+		err := r.createRawMessage(ctx, tx, o.OrderUID, message)
+		if err != nil {
+			return fmt.Errorf("error saving raw message in database: %s", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction: %s", err)
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) createRawMessage(ctx context.Context, tx *sql.Tx, uid string, message *order.RawMessage) error {
+	headers, err := json.Marshal(message.Headers)
+	if err != nil {
+		return fmt.Errorf("error marshalling message headers: %s", err)
+	}
+
+	body, err := r.sealer.Seal(string(message.Body), rawMessageBodyAAD)
+	if err != nil {
+		return fmt.Errorf("error encrypting message body: %s", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx, "insert into order_messages (order_uid, subject, headers, body, received_at) values (?, ?, ?, ?, ?)",
+		uid, message.Subject, string(headers), []byte(body), message.ReceivedAt.UTC(),
+	)
+	return err
+}
+
+// GetRawMessage возвращает исходное сообщение, из которого был получен заказ.
+func (r *SQLiteRepository) GetRawMessage(ctx context.Context, uid string) (*order.RawMessage, error) {
+	var message order.RawMessage
+	var headers []byte
+	err := r.db.QueryRowContext(
+		ctx, "select order_uid, subject, headers, body, received_at from order_messages where order_uid = ?", uid,
+	).Scan(&message.OrderUID, &message.Subject, &headers, &message.Body, &message.ReceivedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, order.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("error fetching raw message from database: %s", err)
+	}
+
+	err = json.Unmarshal(headers, &message.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing message headers: %s", err)
+	}
+
+	body, err := r.sealer.Open(string(message.Body), rawMessageBodyAAD)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting message body: %s", err)
+	}
+
+	message.Body = []byte(body)
+	return &message, nil
+}
+
+// ChangedOrderUIDs возвращает UID заказов, изменённых начиная с момента since.
+func (r *SQLiteRepository) ChangedOrderUIDs(ctx context.Context, since time.Time) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, "select order_uid from orders where updated_at >= ?", since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching changed orders from database: %s", err)
+	}
+
+	uids, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching changed orders from database: %s", err)
+	}
+
+	return uids, nil
+}
+
+// PurgeOrders удаляет не более limit заказов, созданных раньше before, вместе со связанными записями.
+// Если archive равен true, перед удалением заказы сохраняются в таблицу orders_archive.
+func (r *SQLiteRepository) PurgeOrders(ctx context.Context, before time.Time, limit int, archive bool) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %s", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx, "select order_uid from orders where date_created < ? order by date_created limit ?", before.UTC(), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting expired orders: %s", err)
+	}
+
+	uids, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting expired orders: %s", err)
+	}
+
+	for _, uid := range uids {
+		err := r.purgeOrder(ctx, tx, uid, archive)
+		if err != nil {
+			return nil, fmt.Errorf("error purging order %s: %s", uid, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing transaction: %s", err)
+	}
+
+	return uids, nil
+}
+
+func (r *SQLiteRepository) purgeOrder(ctx context.Context, tx *sql.Tx, uid string, archive bool) error {
+	o, err := r.getOrder(ctx, tx, uid)
+	if err != nil {
+		return err
+	}
+
+	if archive {
+		document, err := json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("error marshalling order for archiving: %s", err)
+		}
+
+		_, err = tx.ExecContext(
+			ctx, "insert or ignore into orders_archive (order_uid, date_created, document) values (?, ?, ?)",
+			uid, o.DateCreated.UTC(), string(document),
+		)
+		if err != nil {
+			return fmt.Errorf("error archiving order: %s", err)
+		}
+	}
+
+	statements := []struct {
+		query string
+		arg   any
+	}{
+		{"delete from order_messages where order_uid = ?", uid},
+		{"delete from items where order_uid = ?", uid},
+		{"delete from orders where order_uid = ?", uid},
+		{"delete from deliveries where id = ?", o.Delivery.ID},
+		{"delete from payments where \"transaction\" = ?", o.Payment.Transaction},
+	}
+
+	for _, statement := range statements {
+		_, err := tx.ExecContext(ctx, statement.query, statement.arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		err := rows.Scan(&value)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
@@ -0,0 +1,87 @@
+-- Схема SQLite повторяет схему Postgres (см. internal/migrations/sql). Денежные суммы хранятся строками
+-- в десятичной записи, т.к. в SQLite нет точного десятичного типа.
+
+create table if not exists deliveries
+(
+    id      integer primary key autoincrement,
+    name    text,
+    phone   text,
+    zip     text,
+    city    text,
+    address text,
+    region  text,
+    email   text
+);
+
+create table if not exists payments
+(
+    "transaction" text primary key,
+    request_id    text,
+    currency      text,
+    provider      text,
+    amount        text,
+    payment_dt    integer,
+    bank          text,
+    delivery_cost text,
+    goods_total   text,
+    custom_fee    text
+);
+
+create table if not exists orders
+(
+    order_uid          text primary key,
+    track_number       text,
+    entry              text,
+    delivery_id        integer references deliveries (id),
+    "transaction"      text references payments ("transaction"),
+    locale             text,
+    internal_signature text,
+    customer_id        text,
+    delivery_service   text,
+    shardkey           text,
+    sm_id              integer,
+    date_created       timestamp,
+    oof_shard          text,
+    updated_at         timestamp not null default current_timestamp,
+    state              text      not null default 'created',
+    state_changed_at   timestamp,
+    version            integer   not null default 1
+);
+
+create index if not exists orders_updated_at_idx on orders (updated_at);
+create index if not exists orders_date_created_idx on orders (date_created);
+
+create table if not exists items
+(
+    chrt_id      integer primary key,
+    track_number text,
+    price        text,
+    rid          text,
+    name         text,
+    sale         real,
+    size         text,
+    total_price  text,
+    nm_id        integer,
+    brand        text,
+    status       integer,
+    order_uid    text references orders (order_uid)
+);
+
+create index if not exists items_order_uid_idx on items (order_uid);
+
+create table if not exists order_messages
+(
+    order_uid   text primary key references orders (order_uid),
+    subject     text      not null,
+    headers     text      not null default '{}',
+    body        blob      not null,
+    received_at timestamp not null
+);
+
+create table if not exists orders_archive
+(
+    order_uid    text primary key,
+    date_created timestamp,
+    document     text      not null,
+    archived_at  timestamp not null default current_timestamp
+);
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+	"wb-l0/internal/order/conformance"
+)
+
+func newTestSQLite(t *testing.T, path string) *SQLiteRepository {
+	t.Helper()
+
+	repo, err := NewSQLiteRepositoryFromConfig(context.Background(), config.SQLite{Path: path}, nil)
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+	t.Cleanup(func() { repo.db.Close() })
+
+	return repo
+}
+
+func TestSQLiteStoresStateAndVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestSQLite(t, filepath.Join(t.TempDir(), "orders.db"))
+
+	changedAt := time.Date(2021, time.November, 27, 10, 0, 0, 0, time.UTC)
+	o := conformance.SampleOrder("state", 1)
+	o.State, o.StateChangedAt = order.StateDelivered, changedAt
+
+	created := conformance.SampleOrder("created", 10)
+	before := time.Now()
+	for _, o := range []*order.Order{o, created} {
+		err := repo.CreateOrder(ctx, o)
+		if err != nil {
+			t.Fatalf("error creating order %s: %s", o.OrderUID, err)
+		}
+	}
+
+	got, err := repo.GetOrder(ctx, o.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order: %s", err)
+	}
+
+	if got.State != order.StateDelivered || !got.StateChangedAt.Equal(changedAt) || got.Version != 1 {
+		t.Errorf("got state %s changed at %s, version %d; want %s changed at %s, version 1",
+			got.State, got.StateChangedAt, got.Version, order.StateDelivered, changedAt)
+	}
+
+	got, err = repo.GetOrder(ctx, created.OrderUID)
+	if err != nil {
+		t.Fatalf("error getting order: %s", err)
+	}
+
+	if got.State != order.StateCreated || got.StateChangedAt.Before(before.Add(-time.Second)) {
+		t.Errorf("got state %s changed at %s, want %s changed after %s",
+			got.State, got.StateChangedAt, order.StateCreated, before)
+	}
+}
+
+// sqliteSchemaWithoutState - схема SQLite в том виде, в котором она была до появления состояний и версий заказов.
+func sqliteSchemaWithoutState(t *testing.T) string {
+	t.Helper()
+
+	schema := sqliteSchema
+	for _, column := range []string{
+		",\n    state              text      not null default 'created'",
+		",\n    state_changed_at   timestamp",
+		",\n    version            integer   not null default 1",
+	} {
+		if !strings.Contains(schema, column) {
+			t.Fatalf("schema does not contain column definition %q", column)
+		}
+
+		schema = strings.Replace(schema, column, "", 1)
+	}
+
+	return schema
+}
+
+func TestSQLiteUpgradesSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.ExecContext(ctx, sqliteSchemaWithoutState(t))
+	if err != nil {
+		t.Fatalf("error creating legacy schema: %s", err)
+	}
+
+	savedAt := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+	statements := []string{
+		`insert into payments values ('legacy', '', 'USD', '', '10', 0, '', '0', '10', '0')`,
+		`insert into deliveries values (1, 'Test Testov', '+9720000000', '', '', '', '', 'test@gmail.com')`,
+		`insert into orders values ('legacy', 'TRACK', '', 1, 'legacy', 'en', '', '', '', '', 0,
+		 '2022-03-01 11:00:00', '', '2022-03-01 12:00:00')`,
+	}
+	for _, statement := range statements {
+		_, err := db.ExecContext(ctx, statement)
+		if err != nil {
+			t.Fatalf("error filling legacy database: %s", err)
+		}
+	}
+	db.Close()
+
+	// Схема обновляется при каждом открытии, повторное обновление ничего не меняет.
+	newTestSQLite(t, path).db.Close()
+	repo := newTestSQLite(t, path)
+
+	got, err := repo.GetOrder(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("error getting order saved before upgrade: %s", err)
+	}
+
+	if got.State != order.StateCreated || !got.StateChangedAt.Equal(savedAt) || got.Version != 1 {
+		t.Errorf("got state %s changed at %s, version %d; want %s changed at %s, version 1",
+			got.State, got.StateChangedAt, got.Version, order.StateCreated, savedAt)
+	}
+}
+
+func TestSQLiteStoresRawMessage(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestSQLite(t, filepath.Join(t.TempDir(), "orders.db"))
+	cached := NewCachedRepository("memory", NewMetricsRepository(repo, "sqlite"), NewInMemoryRepository())
+
+	message := &order.RawMessage{
+		Subject:    "orders",
+		Headers:    map[string][]string{"Traceparent": {"00-trace"}},
+		Body:       []byte(`{"order_uid": "raw"}`),
+		ReceivedAt: time.Date(2021, time.November, 27, 10, 0, 0, 0, time.UTC),
+	}
+	err := order.CreateOrderWithMessage(ctx, cached, conformance.SampleOrder("raw", 1), message)
+	if err != nil {
+		t.Fatalf("error creating order: %s", err)
+	}
+
+	got, err := repo.GetRawMessage(ctx, "raw")
+	if err != nil {
+		t.Fatalf("error getting raw message: %s", err)
+	}
+
+	if got.Subject != message.Subject || string(got.Body) != string(message.Body) ||
+		!got.ReceivedAt.Equal(message.ReceivedAt) || got.Headers["Traceparent"][0] != "00-trace" {
+		t.Errorf("got raw message %+v, want %+v", got, message)
+	}
+
+	err = repo.CreateOrder(ctx, conformance.SampleOrder("without-message", 10))
+	if err != nil {
+		t.Fatalf("error creating order: %s", err)
+	}
+
+	if _, err := repo.GetRawMessage(ctx, "without-message"); !errors.Is(err, order.ErrNotFound) {
+		t.Errorf("got error %v for order without message, want order.ErrNotFound", err)
+	}
+}
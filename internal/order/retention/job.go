@@ -0,0 +1,85 @@
+// Package retention реализует политику хранения заказов: периодическое удаление заказов старше заданного возраста.
+package retention
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/order"
+)
+
+// CacheSweeper - локальный кэш, из которого можно удалить все заказы, созданные раньше указанного момента.
+type CacheSweeper interface {
+	DeleteCreatedBefore(before time.Time) int
+}
+
+// Job периодически удаляет устаревшие заказы из хранилища небольшими транзакциями и сбрасывает их копии в кэше.
+//
+// Помимо сброса кэша по UID удалённых заказов, при каждом запуске из локального кэша удаляются все заказы
+// старше допустимого возраста: они могли быть удалены другим экземпляром, пока этот был остановлен,
+// и попасть в кэш из снимка.
+type Job struct {
+	purger      order.Purger
+	invalidator order.Invalidator
+	sweeper     CacheSweeper
+	cfg         config.Retention
+}
+
+func NewJob(purger order.Purger, invalidator order.Invalidator, sweeper CacheSweeper, cfg config.Retention) *Job {
+	return &Job{
+		purger:      purger,
+		invalidator: invalidator,
+		sweeper:     sweeper,
+		cfg:         cfg,
+	}
+}
+
+// Run запускает удаление сразу и затем с заданным периодом до отмены контекста.
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Job) purge(ctx context.Context) {
+	before := time.Now().Add(-j.cfg.MaxAge)
+	if j.sweeper != nil {
+		j.sweeper.DeleteCreatedBefore(before)
+	}
+
+	total := 0
+	for ctx.Err() == nil {
+		uids, err := j.purger.PurgeOrders(ctx, before, j.cfg.BatchSize, j.cfg.Archive)
+		if err != nil {
+			log.Println("error purging expired orders:", err)
+			break
+		}
+
+		for _, uid := range uids {
+			err := j.invalidator.InvalidateOrder(ctx, uid)
+			if err != nil {
+				log.Printf("error evicting purged order %s from cache: %s\n", uid, err)
+			}
+		}
+
+		total += len(uids)
+		if len(uids) < j.cfg.BatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("purged %d orders created before %s\n", total, before.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,90 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"wb-l0/pkg/money"
+)
+
+// Filter - условия отбора заказов. Пустые поля не ограничивают выборку.
+type Filter struct {
+	CustomerID      string
+	DeliveryService string
+	State           State
+	// CreatedFrom и CreatedTo ограничивают date_created полуинтервалом [CreatedFrom, CreatedTo).
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// DefaultSearchLimit и MaxSearchLimit ограничивают количество результатов поиска.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// ErrCursorWithText - курсор передан вместе с текстом запроса: постранично выдаётся только отбор по фильтру.
+var ErrCursorWithText = errors.New("cursor can only be used without search text")
+
+// SearchQuery - запрос полнотекстового поиска заказов по названиям и брендам товаров, имени получателя
+// и городу доставки. Пустой текст означает отбор только по фильтру.
+type SearchQuery struct {
+	Text   string
+	Filter Filter
+	Limit  int
+	// After - позиция, с которой продолжается отбор по фильтру: возвращаются заказы, идущие после неё.
+	// Используется только без текста запроса.
+	After *Cursor
+}
+
+// PageSize возвращает количество результатов, которое будет выдано с учётом ограничений.
+func (q SearchQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DefaultSearchLimit
+	}
+
+	return min(q.Limit, MaxSearchLimit)
+}
+
+// Cursor - позиция в списке заказов, упорядоченном от новых к старым по DateCreated, а при равенстве - по
+// убыванию OrderUID.
+type Cursor struct {
+	DateCreated time.Time `json:"date_created"`
+	OrderUID    string    `json:"order_uid"`
+}
+
+// CursorAfter возвращает позицию сразу после заказа summary.
+func CursorAfter(summary *Summary) *Cursor {
+	return &Cursor{DateCreated: summary.DateCreated, OrderUID: summary.OrderUID}
+}
+
+// Before проверяет, идёт ли позиция c в списке раньше заказа summary, то есть попадёт ли заказ в выдачу после c.
+func (c *Cursor) Before(summary *Summary) bool {
+	if !summary.DateCreated.Equal(c.DateCreated) {
+		return summary.DateCreated.Before(c.DateCreated)
+	}
+
+	return summary.OrderUID < c.OrderUID
+}
+
+// Summary - краткие сведения о заказе, возвращаемые в результатах поиска.
+type Summary struct {
+	OrderUID        string       `json:"order_uid" db:"order_uid"`
+	TrackNumber     string       `json:"track_number" db:"track_number"`
+	CustomerID      string       `json:"customer_id" db:"customer_id"`
+	DeliveryService string       `json:"delivery_service" db:"delivery_service"`
+	DateCreated     time.Time    `json:"date_created" db:"date_created"`
+	State           State        `json:"state" db:"state"`
+	Currency        string       `json:"currency" db:"currency"`
+	Amount          money.Amount `json:"amount" db:"amount"`
+	ItemCount       int          `json:"item_count" db:"item_count"`
+	// Rank - релевантность заказа запросу, чем больше, тем лучше.
+	Rank float32 `json:"rank,omitempty" db:"rank"`
+}
+
+// Searcher - хранилище, поддерживающее поиск заказов. Результаты упорядочены по убыванию релевантности,
+// а при её равенстве - от новых заказов к старым (см. Cursor).
+type Searcher interface {
+	SearchOrders(ctx context.Context, query SearchQuery) ([]*Summary, error)
+}
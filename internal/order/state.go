@@ -0,0 +1,111 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// State - состояние заказа в его жизненном цикле.
+type State string
+
+const (
+	StateCreated    State = "created"
+	StatePaid       State = "paid"
+	StateAssembling State = "assembling"
+	StateShipped    State = "shipped"
+	StateDelivered  State = "delivered"
+	StateCancelled  State = "cancelled"
+	StateReturned   State = "returned"
+)
+
+// transitions - допустимые переходы между состояниями. Отменить можно только ещё не отправленный заказ,
+// вернуть - отправленный или доставленный. Из состояний cancelled и returned переходов нет.
+var transitions = map[State][]State{
+	StateCreated:    {StatePaid, StateCancelled},
+	StatePaid:       {StateAssembling, StateCancelled},
+	StateAssembling: {StateShipped, StateCancelled},
+	StateShipped:    {StateDelivered, StateReturned},
+	StateDelivered:  {StateReturned},
+	StateCancelled:  nil,
+	StateReturned:   nil,
+}
+
+// Valid проверяет, является ли s известным состоянием.
+func (s State) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransitionTo проверяет, допустим ли переход из состояния s в состояние to.
+func (s State) CanTransitionTo(to State) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UnknownStateError - ошибка, возвращаемая при попытке перевести заказ в неизвестное состояние.
+type UnknownStateError struct {
+	State State
+}
+
+func (e *UnknownStateError) Error() string {
+	return fmt.Sprintf("unknown order state \"%s\"", e.State)
+}
+
+// TransitionError - ошибка, возвращаемая при попытке недопустимого перехода между состояниями.
+type TransitionError struct {
+	From State
+	To   State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order cannot change state from %s to %s", e.From, e.To)
+}
+
+// StateTransition - запись о смене состояния заказа. У первой записи, соответствующей получению заказа,
+// нет предыдущего состояния.
+type StateTransition struct {
+	From      *State    `json:"from" db:"from_state"`
+	To        State     `json:"to" db:"to_state"`
+	ChangedAt time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// TransitionTo переводит заказ в состояние to в момент at. Возвращает *UnknownStateError или *TransitionError,
+// если переход невозможен; в этом случае заказ не меняется.
+func (o *Order) TransitionTo(to State, at time.Time) (*StateTransition, error) {
+	if !to.Valid() {
+		return nil, &UnknownStateError{State: to}
+	}
+
+	from := o.State
+	if !from.CanTransitionTo(to) {
+		return nil, &TransitionError{From: from, To: to}
+	}
+
+	o.State = to
+	o.StateChangedAt = at
+	return &StateTransition{From: &from, To: to, ChangedAt: at}, nil
+}
+
+// StateChange - смена состояния заказа.
+type StateChange struct {
+	OrderUID string
+	State    State
+	// Version - ожидаемая версия заказа или AnyVersion.
+	Version int64
+}
+
+// StateRepository - хранилище, поддерживающее смену состояний заказов.
+type StateRepository interface {
+	// ChangeOrderState переводит заказ в новое состояние и возвращает выполненный переход и новую версию заказа.
+	// Возвращает ErrNotFound, если заказа нет, *VersionConflictError, если версия заказа отличается от ожидаемой,
+	// и ошибки Order.TransitionTo, если переход невозможен.
+	ChangeOrderState(ctx context.Context, change StateChange) (*StateTransition, int64, error)
+	// GetStateTransitions возвращает историю смены состояний заказа в порядке изменения.
+	GetStateTransitions(ctx context.Context, uid string) ([]*StateTransition, error)
+}
@@ -0,0 +1,106 @@
+package order
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransitionTo(t *testing.T) {
+	changedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := changedAt.Add(time.Hour)
+
+	tests := []struct {
+		from    State
+		to      State
+		allowed bool
+		unknown bool
+	}{
+		{from: StateCreated, to: StatePaid, allowed: true},
+		{from: StateCreated, to: StateCancelled, allowed: true},
+		{from: StatePaid, to: StateAssembling, allowed: true},
+		{from: StatePaid, to: StateCancelled, allowed: true},
+		{from: StateAssembling, to: StateShipped, allowed: true},
+		{from: StateAssembling, to: StateCancelled, allowed: true},
+		{from: StateShipped, to: StateDelivered, allowed: true},
+		{from: StateShipped, to: StateReturned, allowed: true},
+		{from: StateDelivered, to: StateReturned, allowed: true},
+
+		{from: StateCreated, to: StateCreated},
+		{from: StateCreated, to: StateShipped},
+		{from: StateCreated, to: StateReturned},
+		{from: StatePaid, to: StateCreated},
+		{from: StateAssembling, to: StatePaid},
+		{from: StateShipped, to: StateCancelled},
+		{from: StateDelivered, to: StateCancelled},
+		{from: StateDelivered, to: StateShipped},
+		{from: StateCancelled, to: StateCreated},
+		{from: StateCancelled, to: StatePaid},
+		{from: StateReturned, to: StateDelivered},
+		{from: StateReturned, to: StateCancelled},
+
+		{from: StateCreated, to: "lost", unknown: true},
+		{from: StateCreated, to: "", unknown: true},
+		{from: StateCancelled, to: "Paid", unknown: true},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.from)+"->"+string(test.to), func(t *testing.T) {
+			o := &Order{OrderUID: "test", State: test.from, StateChangedAt: changedAt}
+
+			transition, err := o.TransitionTo(test.to, at)
+			if test.allowed {
+				if err != nil {
+					t.Fatalf("got error %v, want transition", err)
+				}
+
+				if transition.From == nil || *transition.From != test.from || transition.To != test.to ||
+					!transition.ChangedAt.Equal(at) {
+					t.Errorf("got transition %+v, want %s -> %s at %s", transition, test.from, test.to, at)
+				}
+
+				if o.State != test.to || !o.StateChangedAt.Equal(at) {
+					t.Errorf("got order state %s changed at %s, want %s at %s", o.State, o.StateChangedAt, test.to, at)
+				}
+
+				return
+			}
+
+			if transition != nil {
+				t.Errorf("got transition %+v, want nil", transition)
+			}
+
+			if test.unknown {
+				var unknownErr *UnknownStateError
+				if !errors.As(err, &unknownErr) || unknownErr.State != test.to {
+					t.Errorf("got error %v, want *UnknownStateError for %q", err, test.to)
+				}
+			} else {
+				var transitionErr *TransitionError
+				if !errors.As(err, &transitionErr) || transitionErr.From != test.from || transitionErr.To != test.to {
+					t.Errorf("got error %v, want *TransitionError from %s to %s", err, test.from, test.to)
+				}
+			}
+
+			if o.State != test.from || !o.StateChangedAt.Equal(changedAt) {
+				t.Errorf("order changed on error: state %s changed at %s", o.State, o.StateChangedAt)
+			}
+		})
+	}
+}
+
+func TestStateValid(t *testing.T) {
+	for _, state := range []State{
+		StateCreated, StatePaid, StateAssembling, StateShipped, StateDelivered, StateCancelled, StateReturned,
+	} {
+		if !state.Valid() {
+			t.Errorf("state %s is not valid", state)
+		}
+	}
+
+	for _, state := range []State{"", "lost", "CREATED"} {
+		if state.Valid() {
+			t.Errorf("state %q is valid", state)
+		}
+	}
+}
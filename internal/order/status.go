@@ -0,0 +1,33 @@
+package order
+
+import (
+	"context"
+	"time"
+)
+
+// ItemStatusChange - изменение статуса товара в заказе.
+type ItemStatusChange struct {
+	OrderUID string
+	ChrtID   int64
+	Status   int
+	// Version - ожидаемая версия заказа или AnyVersion.
+	Version int64
+}
+
+// ItemStatusRecord - запись истории статусов товара. Первая запись соответствует статусу, с которым товар был
+// получен в составе заказа, и не имеет предыдущего статуса.
+type ItemStatusRecord struct {
+	Status         int       `json:"status" db:"status"`
+	PreviousStatus *int      `json:"previous_status" db:"previous_status"`
+	ChangedAt      time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// ItemStatusRepository - хранилище, ведущее историю статусов товаров.
+type ItemStatusRepository interface {
+	// ApplyItemStatus меняет статус товара, записывает изменение в историю и возвращает новую версию заказа.
+	// Если товар уже имеет этот статус, ничего не меняется. Возвращает ErrNotFound, если в заказе нет такого товара,
+	// и *VersionConflictError, если версия заказа отличается от ожидаемой.
+	ApplyItemStatus(ctx context.Context, change ItemStatusChange) (int64, error)
+	// GetItemStatusHistory возвращает историю статусов товара в порядке изменения.
+	GetItemStatusHistory(ctx context.Context, uid string, chrtID int64) ([]*ItemStatusRecord, error)
+}
@@ -0,0 +1,102 @@
+package order
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+// FieldViolation - нарушение правила проверки в одном поле заказа. Field - путь к полю в JSON-представлении
+// заказа, например, items[2].price.
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError - ошибка проверки заказа, перечисляющая все найденные нарушения, а не только первое.
+// В HTTP API возвращается со статусом 422 и списком нарушений в поле details ответа (см. GetDetails).
+type ValidationError struct {
+	Violations []FieldViolation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.Field + ": " + v.Message
+	}
+
+	return "order is invalid: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) GetStatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// GetDetails возвращает список нарушений для передачи клиенту (см. httperrors.DetailedError).
+func (e *ValidationError) GetDetails() any {
+	return e.Violations
+}
+
+// validator собирает нарушения, найденные при проверке заказа и вложенных в него структур.
+type validator struct {
+	violations []FieldViolation
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.violations = append(v.violations, FieldViolation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) notEmpty(field, value string) {
+	if value == "" {
+		v.add(field, "must not be empty")
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Violations: v.violations}
+}
+
+// fieldPath возвращает путь к полю name структуры, расположенной по пути prefix.
+func fieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+// indexPath возвращает путь к элементу массива, расположенного по пути prefix.
+func indexPath(prefix string, index int) string {
+	return prefix + "[" + strconv.Itoa(index) + "]"
+}
+
+// validEmail проверяет, что строка является адресом электронной почты без отображаемого имени и угловых скобок.
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
+
+// PhoneLength - длина номера телефона, которую допускает столбец deliveries.phone в исходной схеме.
+const PhoneLength = 11
+
+// validPhone проверяет, что номер телефона состоит из PhoneLength символов: только цифр или знака "+" и цифр.
+func validPhone(phone string) bool {
+	if len(phone) != PhoneLength {
+		return false
+	}
+
+	digits := strings.TrimPrefix(phone, "+")
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return digits != ""
+}
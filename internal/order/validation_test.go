@@ -0,0 +1,271 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"wb-l0/pkg/money"
+)
+
+// validationNow - момент проверки заказов в тестах.
+var validationNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// validOrder возвращает корректный заказ из трёх товаров. Все суммы целые, поэтому представимы в любой валюте.
+func validOrder() *Order {
+	items := make([]*Item, 3)
+	for i := range items {
+		items[i] = &Item{
+			ChrtID:      int64(i + 1),
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       money.MustParse("453"),
+			TotalPrice:  money.MustParse("317"),
+		}
+	}
+
+	return &Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Delivery: &Delivery{
+			Phone: "+9720000000",
+			Email: "test@gmail.com",
+		},
+		Payment: &Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Amount:       money.MustParse("1817"),
+			DeliveryCost: money.MustParse("1500"),
+			GoodsTotal:   money.MustParse("317"),
+			CustomFee:    money.MustParse("0"),
+		},
+		Items:       items,
+		Locale:      "en",
+		DateCreated: validationNow.Add(-time.Hour),
+	}
+}
+
+func TestOrderValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(o *Order)
+		fields []string
+	}{
+		{name: "valid", change: func(o *Order) {}},
+		{name: "empty order_uid", change: func(o *Order) { o.OrderUID = "" }, fields: []string{"order_uid"}},
+		{name: "empty track_number", change: func(o *Order) { o.TrackNumber = "" }, fields: []string{"track_number"}},
+		{name: "unknown locale", change: func(o *Order) { o.Locale = "xx" }, fields: []string{"locale"}},
+		{name: "empty locale", change: func(o *Order) { o.Locale = "" }, fields: []string{"locale"}},
+		{name: "locale with region", change: func(o *Order) { o.Locale = "ru-RU" }},
+		{name: "zero date_created", change: func(o *Order) { o.DateCreated = time.Time{} },
+			fields: []string{"date_created"}},
+		{name: "future date_created", change: func(o *Order) { o.DateCreated = validationNow.Add(2 * MaxClockSkew) },
+			fields: []string{"date_created"}},
+		{name: "date_created within clock skew",
+			change: func(o *Order) { o.DateCreated = validationNow.Add(MaxClockSkew) }},
+		{name: "empty state", change: func(o *Order) { o.State = "" }},
+		{name: "known state", change: func(o *Order) { o.State = StateShipped }},
+		{name: "unknown state", change: func(o *Order) { o.State = "lost" }, fields: []string{"state"}},
+		{name: "null delivery", change: func(o *Order) { o.Delivery = nil }, fields: []string{"delivery"}},
+		{name: "invalid phone", change: func(o *Order) { o.Delivery.Phone = "123" },
+			fields: []string{"delivery.phone"}},
+		{name: "invalid email", change: func(o *Order) { o.Delivery.Email = "test" },
+			fields: []string{"delivery.email"}},
+		{name: "null payment", change: func(o *Order) { o.Payment = nil }, fields: []string{"payment"}},
+		{name: "empty transaction", change: func(o *Order) { o.Payment.Transaction = "" },
+			fields: []string{"payment.transaction"}},
+		{name: "unknown currency", change: func(o *Order) { o.Payment.Currency = "ABC" },
+			fields: []string{"payment.currency"}},
+		{name: "negative amount", change: func(o *Order) { o.Payment.Amount = -1 }, fields: []string{"payment.amount"}},
+		{name: "negative delivery_cost", change: func(o *Order) { o.Payment.DeliveryCost = -1 },
+			fields: []string{"payment.delivery_cost"}},
+		{name: "negative goods_total", change: func(o *Order) { o.Payment.GoodsTotal = -1 },
+			fields: []string{"payment.goods_total"}},
+		{name: "negative custom_fee", change: func(o *Order) { o.Payment.CustomFee = -1 },
+			fields: []string{"payment.custom_fee"}},
+		{name: "amounts too precise for currency", change: func(o *Order) {
+			o.Payment.Currency = "JPY"
+			o.Payment.CustomFee = money.MustParse("0.25")
+			o.Items[2].TotalPrice = money.MustParse("317.10")
+		}, fields: []string{"payment.custom_fee", "items[2].total_price"}},
+		{name: "precision is not checked for unknown currency", change: func(o *Order) {
+			o.Payment.Currency = "ABC"
+			o.Items[2].Price = money.MustParse("0.15")
+		}, fields: []string{"payment.currency"}},
+		{name: "null items", change: func(o *Order) { o.Items = nil }, fields: []string{"items"}},
+		{name: "empty items", change: func(o *Order) { o.Items = []*Item{} }},
+		{name: "null item", change: func(o *Order) { o.Items[1] = nil }, fields: []string{"items[1]"}},
+		{name: "zero chrt_id", change: func(o *Order) { o.Items[0].ChrtID = 0 }, fields: []string{"items[0].chrt_id"}},
+		{name: "empty item track_number", change: func(o *Order) { o.Items[1].TrackNumber = "" },
+			fields: []string{"items[1].track_number"}},
+		{name: "negative price", change: func(o *Order) { o.Items[2].Price = -1 }, fields: []string{"items[2].price"}},
+		{name: "negative total_price", change: func(o *Order) { o.Items[2].TotalPrice = -1 },
+			fields: []string{"items[2].total_price"}},
+		{name: "several violations", change: func(o *Order) {
+			o.OrderUID = ""
+			o.Locale = "xx"
+			o.Delivery.Phone = ""
+			o.Delivery.Email = ""
+			o.Payment.Currency = ""
+			o.Items[0] = nil
+			o.Items[2].ChrtID = 0
+			o.Items[2].TrackNumber = ""
+			o.Items[2].Price = -1
+		}, fields: []string{
+			"order_uid", "locale", "delivery.phone", "delivery.email", "payment.currency",
+			"items[0]", "items[2].chrt_id", "items[2].track_number", "items[2].price",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := validOrder()
+			test.change(o)
+
+			var v validator
+			o.validate(&v, validationNow)
+
+			fields := make([]string, len(v.violations))
+			for i, violation := range v.violations {
+				fields[i] = violation.Field
+				if violation.Message == "" {
+					t.Errorf("violation of %s has no message", violation.Field)
+				}
+			}
+
+			if fmt.Sprint(fields) != fmt.Sprint(test.fields) {
+				t.Errorf("got violations in %v, want %v", fields, test.fields)
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	o := validOrder()
+	if err := o.Validate(); err != nil {
+		t.Fatalf("valid order is rejected: %s", err)
+	}
+
+	o.TrackNumber = ""
+	o.Items[2].Price = -1
+
+	var validationErr *ValidationError
+	if err := o.Validate(); !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want *ValidationError", err)
+	}
+
+	if validationErr.GetStatusCode() != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d, want %d", validationErr.GetStatusCode(), http.StatusUnprocessableEntity)
+	}
+
+	violations, ok := validationErr.GetDetails().([]FieldViolation)
+	if !ok || len(violations) != 2 || violations[0].Field != "track_number" || violations[1].Field != "items[2].price" {
+		t.Errorf("got details %+v, want violations of track_number and items[2].price", validationErr.GetDetails())
+	}
+
+	want := "order is invalid: track_number: must not be empty; items[2].price: must not be negative"
+	if validationErr.Error() != want {
+		t.Errorf("got error %q, want %q", validationErr.Error(), want)
+	}
+}
+
+func TestNestedValidatePaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		field string
+	}{
+		{name: "delivery", err: (&Delivery{Phone: "+9720000000"}).Validate(), field: "email"},
+		{name: "payment", err: (&Payment{Currency: "USD"}).Validate(), field: "transaction"},
+		{name: "item", err: (&Item{ChrtID: 1}).Validate(), field: "track_number"},
+	}
+
+	for _, test := range tests {
+		var validationErr *ValidationError
+		if !errors.As(test.err, &validationErr) || len(validationErr.Violations) != 1 ||
+			validationErr.Violations[0].Field != test.field {
+			t.Errorf("%s: got error %v, want violation of %s", test.name, test.err, test.field)
+		}
+	}
+}
+
+func TestValidPhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		valid bool
+	}{
+		{"+9720000000", true},
+		{"89161234567", true},
+		{"", false},
+		{"+", false},
+		{"+972000000", false},
+		{"+97200000000", false},
+		{"8916123456a", false},
+		{"8916-123456", false},
+		{"+++++++++++", false},
+		{"9720000000+", false},
+		{" 9720000000", false},
+		{"８９１６１２３", false},
+	}
+
+	for _, test := range tests {
+		if got := validPhone(test.phone); got != test.valid {
+			t.Errorf("validPhone(%q) = %t, want %t", test.phone, got, test.valid)
+		}
+	}
+}
+
+func TestValidEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"test@gmail.com", true},
+		{"first.last+tag@example.co.uk", true},
+		{"test@localhost", true},
+		{"", false},
+		{"test", false},
+		{"test@", false},
+		{"@gmail.com", false},
+		{"<test@gmail.com>", false},
+		{"Test <test@gmail.com>", false},
+		{" test@gmail.com", false},
+		{"test@gmail.com, other@gmail.com", false},
+	}
+
+	for _, test := range tests {
+		if got := validEmail(test.email); got != test.valid {
+			t.Errorf("validEmail(%q) = %t, want %t", test.email, got, test.valid)
+		}
+	}
+}
+
+func TestKnownLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		known  bool
+	}{
+		{"ru", true},
+		{"en", true},
+		{"zu", true},
+		{"ru-RU", true},
+		{"en_US", true},
+		{"", false},
+		{"xx", false},
+		{"RU", false},
+		{"rus", false},
+		{"ru-", false},
+		{"ru-ru", false},
+		{"ru-RUS", false},
+		{"ru_R1", false},
+		{"ru-RU-x", false},
+		{"-RU", false},
+	}
+
+	for _, test := range tests {
+		if got := KnownLocale(test.locale); got != test.known {
+			t.Errorf("KnownLocale(%q) = %t, want %t", test.locale, got, test.known)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package order
+
+import "fmt"
+
+// AnyVersion - ожидаемая версия заказа, при которой изменение выполняется без проверки версии.
+const AnyVersion int64 = 0
+
+// VersionConflictError - ошибка, возвращаемая при попытке изменить заказ, версия которого отличается от ожидаемой:
+// заказ был изменён после того, как его прочитал автор изменения.
+type VersionConflictError struct {
+	Expected int64
+	Actual   int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("order version is %d, expected %d", e.Actual, e.Expected)
+}
+
+// CheckVersion проверяет, совпадает ли версия заказа actual с ожидаемой версией expected.
+// Возвращает *VersionConflictError, если версии различаются.
+func CheckVersion(expected, actual int64) error {
+	if expected == AnyVersion || expected == actual {
+		return nil
+	}
+
+	return &VersionConflictError{Expected: expected, Actual: actual}
+}
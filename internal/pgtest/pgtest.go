@@ -0,0 +1,90 @@
+// Package pgtest создаёт для тестов и бенчмарков временные базы данных Postgres.
+//
+// Базы данных создаются на сервере из переменной окружения POSTGRES_TEST_URL (в виде URL, например,
+// postgres://postgres@localhost:5432/postgres), к ним применяются миграции, а по завершении теста они удаляются
+// вместе со всеми данными. Если переменная не задана, тесты, которым нужен Postgres, пропускаются.
+package pgtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"wb-l0/internal/config"
+	"wb-l0/internal/migrations"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// URLVariable - переменная окружения с адресом сервера Postgres, на котором создаются временные базы данных.
+const URLVariable = "POSTGRES_TEST_URL"
+
+// NewDatabase создаёт временную базу данных с применёнными миграциями и возвращает параметры подключения к ней.
+// База данных удаляется по завершении теста, поэтому к этому моменту все подключения к ней должны быть
+// закрыты функциями, зарегистрированными в tb.Cleanup позже вызова NewDatabase.
+func NewDatabase(tb testing.TB) config.PostgresConnection {
+	tb.Helper()
+
+	serverURL := os.Getenv(URLVariable)
+	if serverURL == "" {
+		tb.Skipf("%s is not set", URLVariable)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	databaseURL, err := url.Parse(serverURL)
+	if err != nil {
+		tb.Fatalf("%s must be a URL: %s", URLVariable, err)
+	}
+
+	conn, err := pgx.Connect(ctx, serverURL)
+	if err != nil {
+		tb.Fatalf("error connecting to postgres: %s", err)
+	}
+	defer conn.Close(context.Background())
+
+	name := "wbl0_test_" + randomSuffix()
+	_, err = conn.Exec(ctx, "create database "+name)
+	if err != nil {
+		tb.Fatalf("error creating database: %s", err)
+	}
+
+	tb.Cleanup(func() { dropDatabase(tb, serverURL, name) })
+
+	databaseURL.Path = "/" + name
+	cfg := config.PostgresConnection{URL: databaseURL.String(), ReadMode: "aggregated"}
+	err = migrations.MigrateFromConfig(ctx, cfg)
+	if err != nil {
+		tb.Fatalf("error migrating database: %s", err)
+	}
+
+	return cfg
+}
+
+func dropDatabase(tb testing.TB, serverURL string, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	conn, err := pgx.Connect(ctx, serverURL)
+	if err != nil {
+		tb.Errorf("error connecting to postgres to drop database %s: %s", name, err)
+		return
+	}
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "drop database if exists "+name+" with (force)")
+	if err != nil {
+		tb.Errorf("error dropping database %s: %s", name, err)
+	}
+}
+
+func randomSuffix() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -7,6 +7,9 @@ import (
 	"net/http"
 
 	"wb-l0/pkg/httperrors"
+	"wb-l0/pkg/httpresponse"
+
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 type HandlerFunc = func(r *http.Request) (any, error)
@@ -25,6 +28,13 @@ func WrapHandler(handler HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		if response, ok := data.(*httpresponse.Response); ok {
+			for key, values := range response.Header {
+				w.Header()[key] = values
+			}
+			data = response.Data
+		}
+
 		status := http.StatusOK
 		if data == nil {
 			status = http.StatusNoContent
@@ -34,8 +44,34 @@ func WrapHandler(handler HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// StreamHandlerFunc - обработчик, сам записывающий тело ответа, например, при потоковой выгрузке.
+type StreamHandlerFunc = func(w http.ResponseWriter, r *http.Request) error
+
+// WrapStreamHandler отправляет клиенту ошибку обработчика, если тот ещё не начал записывать ответ. Если ответ уже
+// начат, соединение обрывается, чтобы клиент не принял часть ответа за весь ответ.
+func WrapStreamHandler(handler StreamHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		err := handler(ww, r)
+		if err == nil {
+			return
+		}
+
+		if ww.Status() == 0 {
+			// Заголовки, относящиеся к несостоявшемуся ответу, не должны попасть в ответ с ошибкой.
+			w.Header().Del("Content-Disposition")
+			sendError(w, err)
+			return
+		}
+
+		log.Println("error while streaming response:", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
 type errorResponse struct {
 	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
 }
 
 func sendError(w http.ResponseWriter, err error) {
@@ -43,7 +79,12 @@ func sendError(w http.ResponseWriter, err error) {
 	var httpError httperrors.Error
 
 	if errors.As(err, &httpError) {
-		sendData(w, errorResponse{httpError.Error()}, httpError.GetStatusCode())
+		response := errorResponse{Message: httpError.Error()}
+		if detailed, ok := httpError.(httperrors.DetailedError); ok {
+			response.Details = detailed.GetDetails()
+		}
+
+		sendData(w, response, httpError.GetStatusCode())
 		return
 	}
 
@@ -3,20 +3,27 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 
 	"wb-l0/internal/config"
+	"wb-l0/internal/migrations"
+	"wb-l0/internal/tracing"
+	"wb-l0/pkg/envelope"
 	"wb-l0/pkg/httperrors"
 
 	"wb-l0/internal/order"
+	"wb-l0/internal/order/consistency"
 	"wb-l0/internal/order/consumer"
 	orderHttp "wb-l0/internal/order/delivery/http"
 	orderRepository "wb-l0/internal/order/repository"
+	"wb-l0/internal/order/retention"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
@@ -24,6 +31,29 @@ type Server struct {
 	orderConsumer    order.Consumer
 	serverConfig     config.Server
 	shutdownComplete chan struct{}
+
+	// rawMessages - хранилище исходных сообщений, nil если хранилище их не поддерживает.
+	rawMessages order.RawMessageRepository
+	// searcher - поиск заказов, nil если хранилище его не поддерживает.
+	searcher order.Searcher
+	// analytics - показатели продаж, nil если хранилище их не поддерживает.
+	analytics order.Analytics
+	// itemStatuses - история статусов товаров, nil если хранилище её не поддерживает.
+	itemStatuses order.ItemStatusRepository
+	// states - смена состояний заказов, nil если хранилище её не поддерживает.
+	states order.StateRepository
+	// exporter - потоковая выгрузка заказов, nil если хранилище её не поддерживает.
+	exporter order.Exporter
+	// invalidator сбрасывает изменённые заказы из кэша.
+	invalidator order.Invalidator
+	// consistency сверяет кэши с основным хранилищем, consistencySample - размер выборки по умолчанию.
+	consistency       *consistency.Checker
+	consistencySample int
+
+	// workers - фоновые задачи, работающие до отмены контекста сервера.
+	workers []func(ctx context.Context)
+	// shutdownHooks - функции, вызываемые после остановки http-сервера.
+	shutdownHooks []func()
 }
 
 func NewServer(
@@ -38,26 +68,181 @@ func NewServer(
 	}
 }
 
+// storage - основное хранилище заказов вместе с возможностями, которые нужны сервису помимо order.Repository.
+type storage interface {
+	order.Repository
+	order.ChangeTracker
+	order.Purger
+	order.RawMessageRepository
+}
+
 func NewServerFromConfig(ctx context.Context, cfg *config.Config) (*Server, error) {
-	primaryDatabase, err := orderRepository.NewPostgresRepositoryFromConfig(ctx, cfg.Postgres)
+	shutdownTracing, err := tracing.SetupFromConfig(ctx, cfg.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up tracing: %s", err)
+	}
+
+	sealer, err := envelope.NewSealerFromFile(cfg.Encryption.KeyringPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading encryption keyring: %s", err)
+	}
+
+	primaryDatabase, err := newStorageFromConfig(ctx, cfg, sealer)
 	if err != nil {
 		return nil, err
 	}
 
+	// Многоуровневый кэш: память экземпляра -> общий Redis (если включён) -> основное хранилище.
+	var database order.Repository = orderRepository.NewMetricsRepository(primaryDatabase, cfg.Storage.Driver)
+	var consistencyTargets []consistency.Target
+	if cfg.Redis.Enabled {
+		redisRepo := orderRepository.NewRedisRepositoryFromConfig(cfg.Redis, sealer)
+		redisCache := orderRepository.NewMetricsCache(redisRepo, "redis")
+		database = orderRepository.NewCachedRepository("redis", database, redisCache)
+		consistencyTargets = append(consistencyTargets, consistency.Target{Name: "redis", Cache: redisRepo})
+	}
+
 	cache := orderRepository.NewInMemoryRepository()
-	orderRepo := orderRepository.NewCachedRepository(primaryDatabase, cache)
+	orderRepo := orderRepository.NewCachedRepositoryFromConfig("memory", database, cache, cfg.Cache)
+	consistencyTargets = append(consistencyTargets, consistency.Target{Name: "memory", Cache: cache})
 
 	orderConsumer, err := consumer.NewConsumer(cfg.Nats, orderRepo)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewServer(orderRepo, orderConsumer, cfg.Server), nil
+	server := NewServer(orderRepo, orderConsumer, cfg.Server)
+	server.rawMessages = primaryDatabase
+	server.invalidator = orderRepo
+	// Кэши сверяются только с ведущим сервером: реплика может ещё не получить изменения, уже попавшие в кэш.
+	var consistencyDatabase order.Repository = primaryDatabase
+	if postgres, ok := primaryDatabase.(*orderRepository.PostgresRepository); ok {
+		consistencyDatabase = postgres.Primary()
+	}
+
+	server.consistency = consistency.NewChecker(consistencyDatabase, consistencyTargets, cfg.Consistency)
+	server.consistencySample = cfg.Consistency.Sample
+	if itemStatuses, ok := primaryDatabase.(order.ItemStatusRepository); ok {
+		server.itemStatuses = itemStatuses
+	}
+
+	if states, ok := primaryDatabase.(order.StateRepository); ok {
+		server.states = states
+	}
+
+	if exporter, ok := primaryDatabase.(order.Exporter); ok {
+		server.exporter = exporter
+	}
+	if searcher, ok := primaryDatabase.(order.Searcher); ok {
+		server.searcher = searcher
+	}
+
+	if analytics, ok := primaryDatabase.(order.Analytics); ok {
+		server.analytics = analytics
+		if cfg.Analytics.CacheTTL > 0 {
+			server.analytics = orderRepository.NewCachedAnalytics(analytics, cfg.Analytics.CacheTTL)
+		}
+	}
+
+	if cfg.Cache.SnapshotPath != "" {
+		snapshotter := orderRepository.NewSnapshotter(cfg.Cache.SnapshotPath, cache, primaryDatabase, sealer)
+		err := snapshotter.Load(ctx)
+		if err != nil {
+			log.Println("error loading cache snapshot:", err)
+		}
+
+		server.AddShutdownHook(func() {
+			err := snapshotter.Save()
+			if err != nil {
+				log.Println("error saving cache snapshot:", err)
+			}
+		})
+	}
+
+	if cfg.Consistency.Enabled {
+		server.AddWorker(server.consistency.Run)
+	}
+
+	if cfg.Retention.Enabled {
+		job := retention.NewJob(primaryDatabase, orderRepo, cache, cfg.Retention)
+		server.AddWorker(job.Run)
+	}
+
+	if replicated, ok := primaryDatabase.(interface{ RunReplicaHealthChecks(ctx context.Context) }); ok {
+		server.AddWorker(replicated.RunReplicaHealthChecks)
+	}
+
+	// Уведомления об изменениях заказов рассылает только Postgres.
+	if cfg.Cache.ListenChanges {
+		switch cfg.Storage.Driver {
+		case "postgres":
+			listener := orderRepository.NewPostgresListenerFromConfig(cfg.Postgres, orderRepo)
+			server.AddWorker(listener.Run)
+		case "sharded":
+			for _, url := range cfg.Sharding.Shards {
+				listener := orderRepository.NewPostgresListener(url, orderRepository.OrderChangesChannel, orderRepo)
+				server.AddWorker(listener.Run)
+			}
+		}
+	}
+
+	// Трассировки выгружаются последними, чтобы в них попали спаны остальных обработчиков остановки.
+	server.AddShutdownHook(func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			log.Println("error shutting down tracing:", err)
+		}
+	})
+
+	return server, nil
+}
+
+func newStorageFromConfig(ctx context.Context, cfg *config.Config, sealer *envelope.Sealer) (storage, error) {
+	switch cfg.Storage.Driver {
+	case "postgres":
+		if cfg.Postgres.AutoMigrate {
+			err := migrations.MigrateFromConfig(ctx, cfg.Postgres)
+			if err != nil {
+				return nil, fmt.Errorf("error migrating database: %s", err)
+			}
+		}
+
+		return orderRepository.NewPostgresRepositoryFromConfig(ctx, cfg.Postgres, sealer)
+	case "sharded":
+		if cfg.Postgres.AutoMigrate {
+			for name, url := range cfg.Sharding.Shards {
+				shardCfg := cfg.Postgres
+				shardCfg.URL = url
+
+				err := migrations.MigrateFromConfig(ctx, shardCfg)
+				if err != nil {
+					return nil, fmt.Errorf("error migrating shard %s: %s", name, err)
+				}
+			}
+		}
+
+		return orderRepository.NewShardedRepositoryFromConfig(ctx, cfg.Sharding, cfg.Postgres, sealer)
+	case "sqlite":
+		return orderRepository.NewSQLiteRepositoryFromConfig(ctx, cfg.SQLite, sealer)
+	default:
+		return nil, fmt.Errorf("unknown storage driver \"%s\"", cfg.Storage.Driver)
+	}
+}
+
+// AddWorker добавляет фоновую задачу, которая будет запущена вместе с сервером.
+func (s *Server) AddWorker(worker func(ctx context.Context)) {
+	s.workers = append(s.workers, worker)
+}
+
+// AddShutdownHook добавляет функцию, которая будет вызвана при остановке сервера.
+func (s *Server) AddShutdownHook(hook func()) {
+	s.shutdownHooks = append(s.shutdownHooks, hook)
 }
 
 func (s *Server) Run(ctx context.Context) {
 	s.startWebServer(ctx)
 	s.startNatsConsumer(ctx)
+	s.startWorkers(ctx)
 }
 
 func (s *Server) Shutdown(ctx context.Context) {
@@ -67,6 +252,10 @@ func (s *Server) Shutdown(ctx context.Context) {
 	case <-ctx.Done():
 		log.Println("context deadline exceeded")
 	}
+
+	for _, hook := range s.shutdownHooks {
+		hook()
+	}
 }
 
 func (s *Server) startNatsConsumer(ctx context.Context) {
@@ -81,17 +270,59 @@ func (s *Server) startNatsConsumer(ctx context.Context) {
 	}()
 }
 
+func (s *Server) startWorkers(ctx context.Context) {
+	for _, worker := range s.workers {
+		go worker(ctx)
+	}
+}
+
 func (s *Server) startWebServer(ctx context.Context) {
 	router := chi.NewRouter()
 
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
+	router.Use(traceRequests)
+
+	router.Handle("/metrics", promhttp.Handler())
 
 	router.Route("/orders", func(router chi.Router) {
 		handler := orderHttp.NewOrderHandler(s.orderRepository)
 		router.Get("/{id}", WrapHandler(handler.GetOrder))
+		router.Post("/validate", WrapHandler(handler.ValidateOrder))
+
+		if s.itemStatuses != nil {
+			statusHandler := orderHttp.NewItemStatusHandler(s.itemStatuses, s.invalidator)
+			router.Get("/{id}/items/{chrt_id}/history", WrapHandler(statusHandler.GetHistory))
+		}
+
+		if s.states != nil {
+			stateHandler := orderHttp.NewStateHandler(s.states, s.invalidator)
+			router.Get("/{id}/states", WrapHandler(stateHandler.GetTransitions))
+		}
+
+		if s.searcher != nil {
+			searchHandler := orderHttp.NewSearchHandler(s.searcher)
+			router.Get("/", WrapHandler(searchHandler.ListOrders))
+			router.Get("/search", WrapHandler(searchHandler.SearchOrders))
+		}
 	})
 
+	if s.analytics != nil {
+		router.Route("/analytics", func(router chi.Router) {
+			handler := orderHttp.NewAnalyticsHandler(s.analytics)
+			router.Get("/sales", WrapHandler(handler.GetSales))
+		})
+	}
+
+	if s.serverConfig.AdminToken != "" {
+		router.Route("/admin", func(router chi.Router) {
+			router.Use(requireToken(s.serverConfig.AdminToken))
+			s.registerAdminRoutes(router)
+		})
+	} else {
+		log.Println("admin token is not set, admin endpoints are disabled")
+	}
+
 	router.NotFound(ErrorHandler(httperrors.ErrNotFound))
 	router.MethodNotAllowed(ErrorHandler(httperrors.ErrMethodNotAllowed))
 
@@ -127,3 +358,31 @@ func (s *Server) startWebServer(ctx context.Context) {
 		}
 	}()
 }
+
+func (s *Server) registerAdminRoutes(router chi.Router) {
+	if s.rawMessages != nil {
+		handler := orderHttp.NewAdminHandler(s.rawMessages)
+		router.Get("/orders/{id}/raw", WrapHandler(handler.GetRawMessage))
+	}
+
+	if s.itemStatuses != nil {
+		handler := orderHttp.NewItemStatusHandler(s.itemStatuses, s.invalidator)
+		router.Post("/orders/{id}/items/{chrt_id}/status", WrapHandler(handler.ApplyStatus))
+	}
+
+	if s.states != nil {
+		handler := orderHttp.NewStateHandler(s.states, s.invalidator)
+		router.Post("/orders/{id}/state", WrapHandler(handler.ChangeState))
+	}
+
+	if s.exporter != nil {
+		handler := orderHttp.NewExportHandler(s.exporter)
+		router.Get("/orders/export", WrapStreamHandler(handler.ExportOrders))
+	}
+
+	if s.consistency != nil {
+		handler := orderHttp.NewConsistencyHandler(s.consistency, s.consistencySample)
+		router.Get("/cache/consistency", WrapHandler(handler.GetLastReports))
+		router.Post("/cache/consistency", WrapHandler(handler.Check))
+	}
+}
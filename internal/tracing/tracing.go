@@ -0,0 +1,92 @@
+// Package tracing настраивает выгрузку трассировок OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"wb-l0/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// SetupFromConfig устанавливает глобальные TracerProvider и пропагатор контекста трассировки (W3C Trace Context
+// и Baggage) и возвращает функцию, выгружающую накопленные трассировки и освобождающую ресурсы экспортера.
+//
+// Если трассировка отключена, пропагатор всё равно устанавливается, чтобы контекст входящих запросов передавался
+// дальше, а спаны не записываются.
+func SetupFromConfig(ctx context.Context, cfg config.Tracing) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closeOutput, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		return errors.Join(provider.Shutdown(ctx), closeOutput())
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg config.Tracing) (sdktrace.SpanExporter, func() error, error) {
+	noClose := func() error { return nil }
+
+	switch cfg.Exporter {
+	case "otlp":
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.OTLPEndpoint)}
+		if cfg.OTLPInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating otlp trace exporter: %s", err)
+		}
+
+		return exporter, noClose, nil
+	case "stdout":
+		var output io.Writer = os.Stdout
+		closeOutput := noClose
+		if cfg.FilePath != "" {
+			file, err := os.OpenFile(cfg.FilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error opening trace file: %s", err)
+			}
+
+			output, closeOutput = file, file.Close
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(output))
+		if err != nil {
+			_ = closeOutput()
+			return nil, nil, fmt.Errorf("error creating stdout trace exporter: %s", err)
+		}
+
+		return exporter, closeOutput, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown trace exporter \"%s\"", cfg.Exporter)
+	}
+}
@@ -0,0 +1,83 @@
+// Package envelope реализует конвертное шифрование отдельных значений: каждое значение шифруется собственным
+// случайным ключом данных (DEK), который, в свою очередь, шифруется ключом из связки ключей (KEK).
+//
+// Зашифрованное значение хранит идентификатор ключа, которым был зашифрован DEK, поэтому ключи можно
+// менять: новые значения шифруются активным ключом, а старые продолжают расшифровываться, пока их ключ
+// остаётся в связке.
+package envelope
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const keySize = 32
+
+// Keyring - связка ключей шифрования. Файл связки ключей - JSON вида
+//
+//	{"active": "2024-02", "keys": {"2024-02": "<base64>", "2023-11": "<base64>"}}
+//
+// где каждый ключ - 32 случайных байта в base64.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+type keyringFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// LoadKeyring читает связку ключей из файла.
+func LoadKeyring(path string) (*Keyring, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading keyring file: %s", err)
+	}
+
+	var file keyringFile
+	err = json.Unmarshal(content, &file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing keyring file: %s", err)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not valid base64: %s", id, err)
+		}
+
+		keys[id] = key
+	}
+
+	return NewKeyring(file.Active, keys)
+}
+
+// NewKeyring создаёт связку ключей. Активный ключ используется для шифрования новых значений.
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, errors.New("active key is missing from keyring")
+	}
+
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id \"%s\"", id)
+		}
+
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %s must be %d bytes long", id, keySize)
+		}
+	}
+
+	return &Keyring{active: active, keys: keys}, nil
+}
+
+// ActiveKeyID возвращает идентификатор ключа, которым шифруются новые значения.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
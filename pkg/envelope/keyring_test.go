@@ -0,0 +1,65 @@
+package envelope
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		active  string
+		keys    map[string][]byte
+		wantErr bool
+	}{
+		{"valid", "k1", newTestKeys("k1", "k2"), false},
+		{"missing active key", "k3", newTestKeys("k1"), true},
+		{"empty key id", "k1", map[string][]byte{"k1": make([]byte, keySize), "": make([]byte, keySize)}, true},
+		{"colon in key id", "a:b", map[string][]byte{"a:b": make([]byte, keySize)}, true},
+		{"short key", "k1", map[string][]byte{"k1": make([]byte, 16)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.active, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSealerFromFile(t *testing.T) {
+	sealer, err := NewSealerFromFile("")
+	if err != nil || sealer != nil {
+		t.Errorf("got %v, %v for empty path, want disabled sealer", sealer, err)
+	}
+
+	key := base64.StdEncoding.EncodeToString(newTestKeys("k1")["k1"])
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	err = os.WriteFile(path, []byte(`{"active": "k1", "keys": {"k1": "`+key+`"}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealer, err = NewSealerFromFile(path)
+	if err != nil {
+		t.Fatalf("error loading keyring: %s", err)
+	}
+
+	sealed, err := sealer.Seal("value", "aad")
+	if err != nil || sealer.NeedsRotation(sealed) {
+		t.Errorf("got %q, %v, want value sealed with key k1", sealed, err)
+	}
+
+	err = os.WriteFile(path, []byte(`{"active": "k1", "keys": {"k1": "not base64!"}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewSealerFromFile(path); err == nil {
+		t.Error("keyring with invalid key is loaded")
+	}
+}
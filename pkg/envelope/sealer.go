@@ -0,0 +1,170 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix отличает зашифрованные значения от открытых, сохранённых до включения шифрования.
+const prefix = "enc:v1:"
+
+var ErrNoKeyring = errors.New("value is encrypted, but no keyring is configured")
+
+// Sealer шифрует и расшифровывает строковые значения. Зашифрованное значение имеет вид
+//
+//	enc:v1:<id ключа>:<зашифрованный DEK>:<зашифрованное значение>
+//
+// Дополнительные данные (aad) привязывают шифротекст к его назначению, например, к имени поля, чтобы
+// зашифрованные значения нельзя было незаметно поменять местами.
+//
+// Нулевой указатель на Sealer является корректным выключенным шифрованием: значения сохраняются как есть.
+type Sealer struct {
+	keyring *Keyring
+}
+
+func NewSealer(keyring *Keyring) *Sealer {
+	return &Sealer{keyring: keyring}
+}
+
+// NewSealerFromFile загружает связку ключей из файла. Пустой путь означает выключенное шифрование.
+func NewSealerFromFile(path string) (*Sealer, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSealer(keyring), nil
+}
+
+// Seal шифрует значение активным ключом. Пустые значения не шифруются.
+func (s *Sealer) Seal(plaintext string, aad string) (string, error) {
+	if s == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	keyID := s.keyring.active
+	dek := make([]byte, keySize)
+	_, err := rand.Read(dek)
+	if err != nil {
+		return "", fmt.Errorf("error generating data key: %s", err)
+	}
+
+	wrappedKey, err := seal(s.keyring.keys[keyID], dek, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("error wrapping data key: %s", err)
+	}
+
+	ciphertext, err := seal(dek, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("error encrypting value: %s", err)
+	}
+
+	encoding := base64.RawURLEncoding
+	return prefix + keyID + ":" + encoding.EncodeToString(wrappedKey) + ":" + encoding.EncodeToString(ciphertext), nil
+}
+
+// Open расшифровывает значение. Значения без признака шифрования возвращаются как есть.
+func (s *Sealer) Open(value string, aad string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+
+	if s == nil {
+		return "", ErrNoKeyring
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	key, ok := s.keyring.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("key %s is not in keyring", parts[0])
+	}
+
+	encoding := base64.RawURLEncoding
+	wrappedKey, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed data key: %s", err)
+	}
+
+	ciphertext, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed ciphertext: %s", err)
+	}
+
+	dek, err := open(key, wrappedKey, []byte(parts[0]))
+	if err != nil {
+		return "", fmt.Errorf("error unwrapping data key: %s", err)
+	}
+
+	plaintext, err := open(dek, ciphertext, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting value: %s", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsRotation проверяет, нужно ли перешифровать значение: оно не зашифровано вовсе или зашифровано
+// не активным ключом. Пустые значения перешифровывать не нужно.
+func (s *Sealer) NeedsRotation(value string) bool {
+	if s == nil || value == "" {
+		return false
+	}
+
+	return !strings.HasPrefix(value, prefix+s.keyring.active+":")
+}
+
+// IsSealed проверяет, является ли значение зашифрованным.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func seal(key []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key []byte, ciphertext []byte, aad []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
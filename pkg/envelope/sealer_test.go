@@ -0,0 +1,172 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestKeys(ids ...string) map[string][]byte {
+	keys := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		key := make([]byte, keySize)
+		_, _ = rand.Read(key)
+		keys[id] = key
+	}
+
+	return keys
+}
+
+func newTestSealer(t *testing.T, active string, keys map[string][]byte) *Sealer {
+	t.Helper()
+
+	keyring, err := NewKeyring(active, keys)
+	if err != nil {
+		t.Fatalf("error creating keyring: %s", err)
+	}
+
+	return NewSealer(keyring)
+}
+
+func TestSealOpen(t *testing.T) {
+	sealer := newTestSealer(t, "k1", newTestKeys("k1"))
+
+	for _, plaintext := range []string{"Test Testov", "Кирьят-Моцкин", strings.Repeat("x", 10000)} {
+		sealed, err := sealer.Seal(plaintext, "deliveries.name")
+		if err != nil {
+			t.Fatalf("error sealing value: %s", err)
+		}
+
+		if !IsSealed(sealed) || strings.Contains(sealed, plaintext) {
+			t.Errorf("value %q is not sealed: %q", plaintext, sealed)
+		}
+
+		if !strings.HasPrefix(sealed, "enc:v1:k1:") {
+			t.Errorf("sealed value %q does not name active key", sealed)
+		}
+
+		got, err := sealer.Open(sealed, "deliveries.name")
+		if err != nil {
+			t.Fatalf("error opening value: %s", err)
+		}
+
+		if got != plaintext {
+			t.Errorf("got %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func TestSealUsesFreshDataKey(t *testing.T) {
+	sealer := newTestSealer(t, "k1", newTestKeys("k1"))
+
+	first, _ := sealer.Seal("value", "aad")
+	second, _ := sealer.Seal("value", "aad")
+	if first == second {
+		t.Error("sealing the same value twice gives the same ciphertext")
+	}
+}
+
+func TestSealEmptyAndPlaintext(t *testing.T) {
+	sealer := newTestSealer(t, "k1", newTestKeys("k1"))
+
+	sealed, err := sealer.Seal("", "aad")
+	if err != nil || sealed != "" {
+		t.Errorf("got %q, %v for empty value, want it unchanged", sealed, err)
+	}
+
+	got, err := sealer.Open("plain value", "aad")
+	if err != nil || got != "plain value" {
+		t.Errorf("got %q, %v for plaintext value, want it unchanged", got, err)
+	}
+}
+
+func TestNilSealer(t *testing.T) {
+	var sealer *Sealer
+
+	sealed, err := sealer.Seal("value", "aad")
+	if err != nil || sealed != "value" {
+		t.Errorf("got %q, %v from disabled sealer, want value unchanged", sealed, err)
+	}
+
+	if sealer.NeedsRotation("value") {
+		t.Error("disabled sealer requires rotation")
+	}
+
+	encrypted, _ := newTestSealer(t, "k1", newTestKeys("k1")).Seal("value", "aad")
+	if _, err := sealer.Open(encrypted, "aad"); !errors.Is(err, ErrNoKeyring) {
+		t.Errorf("got error %v opening sealed value without keyring, want ErrNoKeyring", err)
+	}
+}
+
+func TestOpenAfterRotation(t *testing.T) {
+	keys := newTestKeys("old", "new")
+	oldSealer := newTestSealer(t, "old", keys)
+	newSealer := newTestSealer(t, "new", keys)
+
+	sealed, err := oldSealer.Seal("value", "aad")
+	if err != nil {
+		t.Fatalf("error sealing value: %s", err)
+	}
+
+	if !newSealer.NeedsRotation(sealed) || !newSealer.NeedsRotation("plain") {
+		t.Error("value sealed with retired key or plaintext does not need rotation")
+	}
+
+	got, err := newSealer.Open(sealed, "aad")
+	if err != nil || got != "value" {
+		t.Fatalf("got %q, %v opening value sealed with retired key", got, err)
+	}
+
+	resealed, err := newSealer.Seal(got, "aad")
+	if err != nil {
+		t.Fatalf("error sealing value: %s", err)
+	}
+
+	if newSealer.NeedsRotation(resealed) {
+		t.Error("value sealed with active key needs rotation")
+	}
+}
+
+func TestOpenRejectsInvalidValues(t *testing.T) {
+	keys := newTestKeys("k1")
+	sealer := newTestSealer(t, "k1", keys)
+	sealed, err := sealer.Seal("value", "deliveries.name")
+	if err != nil {
+		t.Fatalf("error sealing value: %s", err)
+	}
+
+	// Меняется один бит шифротекста, чтобы значение оставалось корректным base64.
+	parts := strings.Split(sealed, ":")
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil {
+		t.Fatalf("error decoding ciphertext: %s", err)
+	}
+	ciphertext[len(ciphertext)/2] ^= 1
+	tampered := strings.Join(append(parts[:4:4], base64.RawURLEncoding.EncodeToString(ciphertext)), ":")
+	swapped := strings.Join([]string{parts[0], parts[1], parts[2], parts[4], parts[3]}, ":")
+
+	tests := []struct {
+		name   string
+		sealer *Sealer
+		value  string
+		aad    string
+	}{
+		{"wrong aad", sealer, sealed, "deliveries.phone"},
+		{"tampered ciphertext", sealer, tampered, "deliveries.name"},
+		{"swapped data key", sealer, swapped, "deliveries.name"},
+		{"malformed value", sealer, "enc:v1:k1:abc", "deliveries.name"},
+		{"unknown key", newTestSealer(t, "k2", newTestKeys("k2")), sealed, "deliveries.name"},
+		{"same key id, other key", newTestSealer(t, "k1", newTestKeys("k1")), sealed, "deliveries.name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.sealer.Open(tt.value, tt.aad)
+			if err == nil {
+				t.Errorf("got %q, want error", got)
+			}
+		})
+	}
+}
@@ -5,4 +5,5 @@ import "net/http"
 var (
 	ErrNotFound         = NewHttpError("requested resource was not found on the server", http.StatusNotFound)
 	ErrMethodNotAllowed = NewHttpError("method not allowed", http.StatusMethodNotAllowed)
+	ErrUnauthorized     = NewHttpError("authorization is required to access this resource", http.StatusUnauthorized)
 )
@@ -21,3 +21,10 @@ func (e HttpError) Error() string {
 func (e HttpError) GetStatusCode() int {
 	return e.Status
 }
+
+// DetailedError - ошибка, к которой прилагаются подробности для клиента, например, список нарушений
+// при проверке тела запроса. Подробности передаются в поле details ответа.
+type DetailedError interface {
+	Error
+	GetDetails() any
+}
@@ -0,0 +1,20 @@
+package httpresponse
+
+import "net/http"
+
+// Response - ответ обработчика, которому нужно передать клиенту заголовки помимо тела ответа.
+type Response struct {
+	Data   any
+	Header http.Header
+}
+
+// New создаёт ответ с телом data и без дополнительных заголовков.
+func New(data any) *Response {
+	return &Response{Data: data, Header: make(http.Header)}
+}
+
+// WithHeader устанавливает заголовок ответа и возвращает сам ответ.
+func (r *Response) WithHeader(key, value string) *Response {
+	r.Header.Set(key, value)
+	return r
+}
@@ -0,0 +1,216 @@
+// Package money содержит точное представление денежных сумм, не подверженное ошибкам округления float64.
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Scale - количество знаков после запятой, с которым хранятся суммы. Совпадает с масштабом
+// столбцов numeric(10, 2) в базе данных.
+const Scale = 2
+
+// MaxAmount - наибольшая по модулю сумма, которую можно сохранить в столбец numeric(10, 2).
+const MaxAmount Amount = 99999999_99
+
+const unitsPerWhole = 100
+
+var (
+	ErrTooPrecise = errors.New("amount has more than 2 decimal places")
+	ErrOutOfRange = errors.New("amount does not fit into numeric(10, 2)")
+)
+
+// Amount - денежная сумма, хранящаяся как целое количество сотых долей единицы валюты.
+//
+// В JSON сумма кодируется числом в десятичной записи без потери точности (например, 1817.5) и может быть
+// прочитана как из числа, так и из строки. Суммы, не представимые без округления, отвергаются.
+type Amount int64
+
+// Parse разбирает сумму в десятичной записи, допускается экспоненциальная запись.
+func Parse(s string) (Amount, error) {
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount \"%s\"", s)
+	}
+
+	return fromRat(value)
+}
+
+// MustParse - то же, что Parse, но вызывает panic при ошибке. Предназначена для констант.
+func MustParse(s string) Amount {
+	amount, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+func fromRat(value *big.Rat) (Amount, error) {
+	value = new(big.Rat).Mul(value, big.NewRat(unitsPerWhole, 1))
+	if !value.IsInt() {
+		return 0, ErrTooPrecise
+	}
+
+	units := value.Num()
+	if !units.IsInt64() {
+		return 0, ErrOutOfRange
+	}
+
+	amount := Amount(units.Int64())
+	if amount > MaxAmount || amount < -MaxAmount {
+		return 0, ErrOutOfRange
+	}
+
+	return amount, nil
+}
+
+// Units возвращает сумму в сотых долях единицы валюты.
+func (a Amount) Units() int64 {
+	return int64(a)
+}
+
+// String возвращает сумму в десятичной записи без лишних нулей после запятой: 1817, 1817.5, 0.05.
+func (a Amount) String() string {
+	sign := ""
+	units := int64(a)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	whole, fraction := units/unitsPerWhole, units%unitsPerWhole
+	if fraction == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+
+	fractionText := strings.TrimRight(fmt.Sprintf("%02d", fraction), "0")
+	return sign + strconv.FormatInt(whole, 10) + "." + fractionText
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	amount, err := Parse(text)
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// ScanNumeric реализует pgtype.NumericScanner. NULL считается нулевой суммой.
+func (a *Amount) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		*a = 0
+		return nil
+	}
+
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return errors.New("cannot scan non-finite numeric into amount")
+	}
+
+	value := new(big.Rat).SetInt(n.Int)
+	exponent := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(n.Exp))), nil)
+	if n.Exp >= 0 {
+		value.Mul(value, new(big.Rat).SetInt(exponent))
+	} else {
+		value.Quo(value, new(big.Rat).SetInt(exponent))
+	}
+
+	amount, err := fromRat(value)
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// NumericValue реализует pgtype.NumericValuer.
+func (a Amount) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(a)), Exp: -Scale, Valid: true}, nil
+}
+
+func abs(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Value реализует driver.Valuer: сумма сохраняется строкой в десятичной записи, чтобы не потерять точность
+// в базах данных без точного десятичного типа.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan реализует sql.Scanner. NULL считается нулевой суммой.
+func (a *Amount) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case int64:
+		return a.setParsed(Parse(strconv.FormatInt(value, 10)))
+	case string:
+		return a.setParsed(Parse(value))
+	case []byte:
+		return a.setParsed(Parse(string(value)))
+	default:
+		return fmt.Errorf("cannot scan %T into amount", src)
+	}
+}
+
+func (a *Amount) setParsed(amount Amount, err error) error {
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// DivideRounded делит сумму на n с округлением до сотых долей, половина округляется от нуля. Используется
+// для расчёта средних значений.
+func (a Amount) DivideRounded(n int64) Amount {
+	if n == 0 {
+		return 0
+	}
+
+	quotient, remainder := int64(a)/n, int64(a)%n
+	if 2*abs64(remainder) >= abs64(n) {
+		if (int64(a) < 0) != (n < 0) {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+
+	return Amount(quotient)
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
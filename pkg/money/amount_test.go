@@ -0,0 +1,149 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Amount
+		err   error
+	}{
+		{input: "0", want: 0},
+		{input: "-0", want: 0},
+		{input: "1817", want: 1817_00},
+		{input: "1817.5", want: 1817_50},
+		{input: "1817.50", want: 1817_50},
+		{input: "0.05", want: 5},
+		{input: "-0.05", want: -5},
+		{input: "-1817.5", want: -1817_50},
+		{input: "1.5e2", want: 150_00},
+		{input: "1e-2", want: 1},
+		{input: "99999999.99", want: MaxAmount},
+		{input: "-99999999.99", want: -MaxAmount},
+		{input: "0.001", err: ErrTooPrecise},
+		{input: "-1.005", err: ErrTooPrecise},
+		{input: "100000000", err: ErrOutOfRange},
+		{input: "-100000000", err: ErrOutOfRange},
+		{input: "1e30", err: ErrOutOfRange},
+		{input: "", err: errInvalid},
+		{input: "abc", err: errInvalid},
+		{input: "1,5", err: errInvalid},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.input)
+		switch {
+		case test.err == errInvalid:
+			if err == nil || errors.Is(err, ErrTooPrecise) || errors.Is(err, ErrOutOfRange) {
+				t.Errorf("Parse(%q): got error %v, want invalid amount error", test.input, err)
+			}
+		case test.err != nil:
+			if !errors.Is(err, test.err) {
+				t.Errorf("Parse(%q): got error %v, want %v", test.input, err, test.err)
+			}
+		case err != nil:
+			t.Errorf("Parse(%q): unexpected error %s", test.input, err)
+		case got != test.want:
+			t.Errorf("Parse(%q) = %d, want %d", test.input, got, test.want)
+		}
+	}
+}
+
+// errInvalid обозначает в таблице тестов ошибку разбора записи, не являющейся числом.
+var errInvalid = errors.New("invalid amount")
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{0, "0"},
+		{5, "0.05"},
+		{50, "0.5"},
+		{1817_00, "1817"},
+		{1817_50, "1817.5"},
+		{1817_05, "1817.05"},
+		{-5, "-0.05"},
+		{-1817_50, "-1817.5"},
+		{MaxAmount, "99999999.99"},
+		{-MaxAmount, "-99999999.99"},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.String(); got != test.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(test.amount), got, test.want)
+		}
+
+		parsed, err := Parse(test.want)
+		if err != nil || parsed != test.amount {
+			t.Errorf("Parse(%q) = %d, %v, want %d", test.want, parsed, err, test.amount)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Amount
+	}{
+		{`1817.5`, 1817_50},
+		{`"1817.5"`, 1817_50},
+		{`-0.05`, -5},
+		{`null`, 0},
+	}
+
+	for _, test := range tests {
+		var got Amount
+		err := json.Unmarshal([]byte(test.input), &got)
+		if err != nil {
+			t.Errorf("unmarshalling %s: unexpected error %s", test.input, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("unmarshalling %s: got %d, want %d", test.input, got, test.want)
+		}
+	}
+
+	var amount Amount
+	if err := json.Unmarshal([]byte(`0.001`), &amount); !errors.Is(err, ErrTooPrecise) {
+		t.Errorf("unmarshalling 0.001: got error %v, want %v", err, ErrTooPrecise)
+	}
+
+	data, err := json.Marshal(struct{ Price Amount }{Price: 1817_50})
+	if err != nil || string(data) != `{"Price":1817.5}` {
+		t.Errorf("got %s, %v when marshalling, want {\"Price\":1817.5}", data, err)
+	}
+}
+
+func TestDivideRounded(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		n      int64
+		want   Amount
+	}{
+		{amount: 100_00, n: 4, want: 25_00},
+		{amount: 10_00, n: 3, want: 3_33},
+		{amount: 20_00, n: 3, want: 6_67},
+		// Половина округляется от нуля.
+		{amount: 5, n: 2, want: 3},
+		{amount: -5, n: 2, want: -3},
+		{amount: 5, n: -2, want: -3},
+		{amount: -5, n: -2, want: 3},
+		{amount: -10_00, n: 3, want: -3_33},
+		{amount: -20_00, n: 3, want: -6_67},
+		{amount: 1, n: 3, want: 0},
+		{amount: 0, n: 7, want: 0},
+		{amount: 100, n: 0, want: 0},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.DivideRounded(test.n); got != test.want {
+			t.Errorf("%s.DivideRounded(%d) = %s, want %s", test.amount, test.n, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minorUnits - количество знаков после запятой в валютах по ISO 4217. Валюты, отсутствующие в таблице,
+// считаются имеющими два знака.
+var minorUnits = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KMF": 0,
+	"KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0,
+	"VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// currencies - действующие коды валют по ISO 4217.
+var currencies = map[string]struct{}{}
+
+func init() {
+	codes := "AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BOV BRL BSD BTN BWP BYN BZD " +
+		"CAD CDF CHE CHF CHW CLF CLP CNY COP COU CRC CUC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL " +
+		"GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD " +
+		"KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO NOK NPR " +
+		"NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SLL SOS SRD SSP STN " +
+		"SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV WST XAF XAG " +
+		"XAU XBA XBB XBC XBD XCD XDR XOF XPD XPF XPT XSU XTS XUA XXX YER ZAR ZMW ZWL "
+
+	for _, code := range strings.Fields(codes) {
+		currencies[code] = struct{}{}
+	}
+}
+
+// KnownCurrency проверяет, что currency - действующий код валюты по ISO 4217 (например, RUB).
+func KnownCurrency(currency string) bool {
+	_, ok := currencies[currency]
+	return ok
+}
+
+// MinorUnits возвращает количество знаков после запятой для валюты с указанным кодом ISO 4217.
+func MinorUnits(currency string) int {
+	if units, ok := minorUnits[currency]; ok {
+		return units
+	}
+	return Scale
+}
+
+// CheckCurrency проверяет, что сумма представима в указанной валюте: например, сумма в иенах не может
+// содержать дробной части. Для валют с тремя знаками после запятой третий знак хранить негде, поэтому
+// для них действует общее ограничение в два знака.
+func (a Amount) CheckCurrency(currency string) error {
+	units := MinorUnits(currency)
+	if units >= Scale {
+		return nil
+	}
+
+	step := int64(1)
+	for i := units; i < Scale; i++ {
+		step *= 10
+	}
+
+	if int64(a)%step != 0 {
+		return fmt.Errorf("amount %s has more than %d decimal places allowed for %s", a, units, currency)
+	}
+
+	return nil
+}
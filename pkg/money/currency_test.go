@@ -0,0 +1,42 @@
+package money
+
+import "testing"
+
+func TestCheckCurrency(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		valid    bool
+	}{
+		{"1817.5", "USD", true},
+		{"0.01", "RUB", true},
+		{"-0.01", "EUR", true},
+		{"453", "JPY", true},
+		{"-453", "JPY", true},
+		{"0", "JPY", true},
+		{"453.5", "JPY", false},
+		{"-0.01", "JPY", false},
+		{"1.1", "KRW", false},
+		// Для валют с тремя знаками после запятой действует общее ограничение в два знака.
+		{"1.25", "BHD", true},
+		{"-1.25", "KWD", true},
+		// Валюты, отсутствующие в таблице, считаются имеющими два знака.
+		{"1.25", "XYZ", true},
+	}
+
+	for _, test := range tests {
+		err := MustParse(test.amount).CheckCurrency(test.currency)
+		if (err == nil) != test.valid {
+			t.Errorf("CheckCurrency(%s, %s): got error %v, want valid = %t", test.amount, test.currency, err, test.valid)
+		}
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	tests := map[string]int{"USD": 2, "RUB": 2, "JPY": 0, "KRW": 0, "BHD": 3, "UNKNOWN": 2}
+	for currency, want := range tests {
+		if got := MinorUnits(currency); got != want {
+			t.Errorf("MinorUnits(%s) = %d, want %d", currency, got, want)
+		}
+	}
+}